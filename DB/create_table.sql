@@ -23,10 +23,78 @@ USE `new_db_collection` ;
 CREATE TABLE IF NOT EXISTS `new_db_collection`.`organization` (
   `org_id` BIGINT NOT NULL AUTO_INCREMENT,
   `org_name` VARCHAR(45) NULL,
+  `restore_budget` DECIMAL(12,4) NULL,
   PRIMARY KEY (`org_id`))
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`restore_spending`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`restore_spending` (
+  `org_id` BIGINT NOT NULL,
+  `period` CHAR(7) NOT NULL,
+  `cost` DECIMAL(12,4) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`, `period`),
+  CONSTRAINT `fk_restore_spending_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`api_quotas`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`api_quotas` (
+  `org_id` BIGINT NOT NULL,
+  `daily_calls` BIGINT NOT NULL DEFAULT 0,
+  `daily_bytes` BIGINT NOT NULL DEFAULT 0,
+  `monthly_calls` BIGINT NOT NULL DEFAULT 0,
+  `monthly_bytes` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`),
+  CONSTRAINT `fk_api_quotas_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`api_usage`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`api_usage` (
+  `org_id` BIGINT NOT NULL,
+  `period` VARCHAR(10) NOT NULL,
+  `calls` BIGINT NOT NULL DEFAULT 0,
+  `bytes` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`, `period`),
+  CONSTRAINT `fk_api_usage_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`feature_flags`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`feature_flags` (
+  `org_id` BIGINT NOT NULL,
+  `flag` VARCHAR(64) NOT NULL,
+  `enabled` TINYINT(1) NOT NULL,
+  PRIMARY KEY (`org_id`, `flag`),
+  CONSTRAINT `fk_feature_flags_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `new_db_collection`.`system_users`
 -- -----------------------------------------------------
@@ -50,6 +118,27 @@ CREATE TABLE IF NOT EXISTS `new_db_collection`.`system_users` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`invitations`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`invitations` (
+  `invite_token` VARCHAR(64) NOT NULL,
+  `org_id` BIGINT NOT NULL,
+  `email_domain` VARCHAR(255) NOT NULL DEFAULT '',
+  `quota_total` INT NOT NULL,
+  `created_by` BIGINT NOT NULL,
+  `expires_at` DATETIME NOT NULL,
+  `max_uses` INT NOT NULL DEFAULT 1,
+  `use_count` INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`invite_token`),
+  CONSTRAINT `fk_invitations_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `new_db_collection`.`authentication_tokens`
 -- -----------------------------------------------------
@@ -69,6 +158,47 @@ CREATE TABLE IF NOT EXISTS `new_db_collection`.`authentication_tokens` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`notifications`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`notifications` (
+  `notification_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT NOT NULL,
+  `type` VARCHAR(45) NOT NULL,
+  `message` VARCHAR(255) NOT NULL,
+  `archive_id` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` DATETIME NOT NULL,
+  `seen` TINYINT(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`notification_id`),
+  INDEX `fk_notifications_system_users_idx` (`user_id` ASC) VISIBLE,
+  CONSTRAINT `fk_notifications_system_users`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `new_db_collection`.`system_users` (`user_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `new_db_collection`.`restore_jobs`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`restore_jobs` (
+  `job_id` VARCHAR(255) NOT NULL,
+  `archive_id` VARCHAR(255) NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `org_id` BIGINT NOT NULL,
+  `status` VARCHAR(45) NOT NULL,
+  `cost` DECIMAL(12,4) NOT NULL DEFAULT 0,
+  `bytes` BIGINT NOT NULL DEFAULT 0,
+  `created_at` DATETIME NOT NULL,
+  `completed_at` DATETIME NULL,
+  `owner` VARCHAR(255) NULL,
+  PRIMARY KEY (`job_id`),
+  INDEX `restore_jobs_archive_status_idx` (`archive_id` ASC, `status` ASC) VISIBLE,
+  INDEX `restore_jobs_org_created_idx` (`org_id` ASC, `created_at` ASC) VISIBLE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `new_db_collection`.`library`
 -- -----------------------------------------------------
@@ -95,9 +225,10 @@ CREATE TABLE IF NOT EXISTS `new_db_collection`.`files` (
   `library_id` BIGINT NOT NULL,
   `user_id` BIGINT NOT NULL,
   `file_name` VARCHAR(255) NOT NULL,
-  `uplod_date` DATETIME NOT NULL,
+  `upload_date` DATETIME NOT NULL,
   `file_size` VARCHAR(255) NOT NULL,
   `file_checksum` VARCHAR(255) NOT NULL,
+  `file_sha256` CHAR(64) NOT NULL DEFAULT '',
   `file_state` VARCHAR(45) NOT NULL,
   PRIMARY KEY (`vault_file_id`),
   UNIQUE KEY `file_id_UNIQUE` (`vault_file_id`)
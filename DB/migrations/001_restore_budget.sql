@@ -0,0 +1,17 @@
+-- Restore budgets of organizations and their monthly restore spending.
+-- For databases created before the restore budget was added to create_table.sql.
+
+ALTER TABLE `new_db_collection`.`organization`
+  ADD COLUMN `restore_budget` DECIMAL(12,4) NULL;
+
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`restore_spending` (
+  `org_id` BIGINT NOT NULL,
+  `period` CHAR(7) NOT NULL,
+  `cost` DECIMAL(12,4) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`, `period`),
+  CONSTRAINT `fk_restore_spending_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
@@ -0,0 +1,29 @@
+-- API quotas of organizations and their daily and monthly API usage.
+-- For databases created before API quotas were counted per organization.
+
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`api_quotas` (
+  `org_id` BIGINT NOT NULL,
+  `daily_calls` BIGINT NOT NULL DEFAULT 0,
+  `daily_bytes` BIGINT NOT NULL DEFAULT 0,
+  `monthly_calls` BIGINT NOT NULL DEFAULT 0,
+  `monthly_bytes` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`),
+  CONSTRAINT `fk_api_quotas_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `new_db_collection`.`api_usage` (
+  `org_id` BIGINT NOT NULL,
+  `period` VARCHAR(10) NOT NULL,
+  `calls` BIGINT NOT NULL DEFAULT 0,
+  `bytes` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`org_id`, `period`),
+  CONSTRAINT `fk_api_usage_organization`
+    FOREIGN KEY (`org_id`)
+    REFERENCES `new_db_collection`.`organization` (`org_id`)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
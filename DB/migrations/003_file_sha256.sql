@@ -0,0 +1,11 @@
+-- SHA-256 of the content of uploaded files.
+-- For databases created before the hash was stored with the files. Archives uploaded
+-- before keep an empty hash, so they are not checked on restore nor reported as duplicates.
+
+ALTER TABLE `new_db_collection`.`files`
+  ADD COLUMN `file_sha256` CHAR(64) NOT NULL DEFAULT '' AFTER `file_checksum`;
+
+-- create_table.sql used to misspell the upload date column, which the queries never matched.
+-- Skip this statement if the column is already called upload_date.
+ALTER TABLE `new_db_collection`.`files`
+  RENAME COLUMN `uplod_date` TO `upload_date`;
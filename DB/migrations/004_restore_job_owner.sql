@@ -0,0 +1,5 @@
+-- Instance of the server waiting for each restore job.
+-- Jobs in progress when the column is added have no owner, so the first instance started afterwards claims them.
+
+ALTER TABLE `new_db_collection`.`restore_jobs`
+  ADD COLUMN `owner` VARCHAR(255) NULL AFTER `completed_at`;
@@ -0,0 +1,192 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const w3cFields = "#Fields: date time cs-method cs-uri-stem sc-status sc-bytes time-taken cs-username x-org x-archive"
+
+type Options struct {
+	Path string
+	// "json" (default) or "w3c"
+	Format  string
+	MaxSize int64
+	MaxAge  time.Duration
+	// optional syslog server the lines are shipped to, e.g. udp://logs.example.com:514
+	SyslogAddress string
+}
+
+type Entry struct {
+	Time       string `json:"time"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Bytes      int    `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	UserId     int    `json:"user_id,omitempty"`
+	OrgId      int    `json:"org_id,omitempty"`
+	ArchiveId  string `json:"archive_id,omitempty"`
+}
+
+// Gin middleware writing one access log line per request, separate from the app logs
+func New(options Options) (gin.HandlerFunc, error) {
+	w3c := options.Format == "w3c"
+	file := &rotatingFile{path: options.Path, maxSize: options.MaxSize, maxAge: options.MaxAge, w3c: w3c}
+	if err := file.rotate(); err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = file
+	if options.SyslogAddress != "" {
+		address, err := url.Parse(options.SyslogAddress)
+		if err != nil {
+			return nil, err
+		}
+		shipper, err := dialSyslog(address)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(file, shipper)
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := Entry{
+			Time:       start.UTC().Format(time.RFC3339),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			Bytes:      c.Writer.Size(),
+			DurationMs: time.Since(start).Milliseconds(),
+			UserId:     c.GetInt("user_id"),
+			OrgId:      c.GetInt("org_id"),
+			ArchiveId:  archiveId(c.Request),
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+
+		var line []byte
+		if w3c {
+			line = []byte(formatW3C(entry))
+		} else {
+			line, _ = json.Marshal(entry)
+		}
+		out.Write(append(line, '\n'))
+	}, nil
+}
+
+// Archive the request was about, without reading a body the handler left alone
+func archiveId(r *http.Request) string {
+	if r.Form != nil {
+		return r.Form.Get("archiveId")
+	}
+	return r.URL.Query().Get("archiveId")
+}
+
+func formatW3C(e Entry) string {
+	field := func(value string) string {
+		if value == "" || value == "0" {
+			return "-"
+		}
+		return strings.ReplaceAll(value, " ", "+")
+	}
+	at, _ := time.Parse(time.RFC3339, e.Time)
+	return strings.Join([]string{
+		at.Format("2006-01-02"),
+		at.Format("15:04:05"),
+		e.Method,
+		field(e.Path),
+		strconv.Itoa(e.Status),
+		strconv.Itoa(e.Bytes),
+		strconv.FormatInt(e.DurationMs, 10),
+		field(strconv.Itoa(e.UserId)),
+		field(strconv.Itoa(e.OrgId)),
+		field(e.ArchiveId),
+	}, " ")
+}
+
+// File that is moved aside and started again once it grows past maxSize or gets older than maxAge
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	w3c     bool
+	file    *os.File
+	size    int64
+	opened  time.Time
+}
+
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	full := w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize
+	old := w.maxAge > 0 && time.Since(w.opened) > w.maxAge
+	if (full || old) && w.size > 0 {
+		// a failed rotation keeps writing to the current file
+		if err := w.rotateLocked(); err != nil && w.file == nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingFile) rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotateLocked()
+}
+
+func (w *rotatingFile) rotateLocked() error {
+	var renameErr error
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+		rotated := fmt.Sprintf("%s.%s", w.path, time.Now().Format("20060102-150405.000"))
+		// when the file can't be moved aside it is opened again below and rotation is retried later
+		renameErr = os.Rename(w.path, rotated)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if w.w3c && w.size == 0 {
+		header := fmt.Sprintf("#Version: 1.0\n#Date: %s\n%s\n", w.opened.UTC().Format("2006-01-02 15:04:05"), w3cFields)
+		n, err := f.WriteString(header)
+		w.size += int64(n)
+		return err
+	}
+	return nil
+}
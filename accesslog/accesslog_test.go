@@ -0,0 +1,111 @@
+package accesslog_test
+
+import (
+	"cool-storage-api/accesslog"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serve(t *testing.T, options accesslog.Options, path string) {
+	gin.SetMode(gin.TestMode)
+	middleware, err := accesslog.New(options)
+	if err != nil {
+		t.Fatalf("Expected %v but got %v", nil, err)
+	}
+	r := gin.New()
+	r.Use(middleware)
+	r.GET("/api/v1/get-archive", func(c *gin.Context) {
+		c.Set("user_id", 7)
+		c.String(http.StatusOK, "pong")
+	})
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestNew_WithJSONFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	serve(t, accesslog.Options{Path: path}, "/api/v1/get-archive?archiveId=abc")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got accesslog.Entry
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "GET" || got.Path != "/api/v1/get-archive" || got.Status != 200 || got.Bytes != 4 || got.UserId != 7 || got.ArchiveId != "abc" {
+		t.Errorf("Expected %v,%v,%v,%v,%v,%v but got %+v", "GET", "/api/v1/get-archive", 200, 4, 7, "abc", got)
+	}
+}
+
+func TestNew_WithW3CFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	serve(t, accesslog.Options{Path: path, Format: "w3c"}, "/api/v1/get-archive")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || lines[0] != "#Version: 1.0" || !strings.HasPrefix(lines[2], "#Fields: ") {
+		t.Fatalf("Expected a W3C header and one line but got %q", lines)
+	}
+	fields := strings.Fields(lines[3])
+	if len(fields) != 10 || fields[2] != "GET" || fields[4] != "200" || fields[7] != "7" || fields[9] != "-" {
+		t.Errorf("Expected %v,%v,%v,%v but got %q", "GET", "200", "7", "-", fields)
+	}
+}
+
+func TestNew_WithRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	options := accesslog.Options{Path: path, MaxSize: 100}
+	serve(t, options, "/api/v1/get-archive")
+	serve(t, options, "/api/v1/get-archive")
+
+	files, err := filepath.Glob(filepath.Join(dir, "access.log*"))
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected %v,%v but got %v,%v", 2, nil, len(files), err)
+	}
+}
+
+func TestNew_WithFailedRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "access.log")
+	middleware, err := accesslog.New(accesslog.Options{Path: path, MaxSize: 100})
+	if err != nil {
+		t.Fatalf("Expected %v but got %v", nil, err)
+	}
+	r := gin.New()
+	r.Use(middleware)
+	r.GET("/api/v1/get-archive", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	get := func() {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/get-archive", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get()
+	// the file can't be moved aside any more, so the next rotation fails
+	os.Remove(path)
+	get()
+	get()
+
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Count(string(data), "\n") < 1 {
+		t.Errorf("Expected the log to go on after a failed rotation but got %q,%v", data, err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package accesslog
+
+import (
+	"io"
+	"log/syslog"
+	"net/url"
+)
+
+// Writer shipping lines to a syslog server
+func dialSyslog(address *url.URL) (io.Writer, error) {
+	return syslog.Dial(address.Scheme, address.Host, syslog.LOG_INFO|syslog.LOG_LOCAL0, "cool-storage-api")
+}
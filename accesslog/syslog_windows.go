@@ -0,0 +1,12 @@
+package accesslog
+
+import (
+	"errors"
+	"io"
+	"net/url"
+)
+
+// log/syslog is not available on Windows
+func dialSyslog(address *url.URL) (io.Writer, error) {
+	return nil, errors.New("shipping the access log to syslog is not supported on windows")
+}
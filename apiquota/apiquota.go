@@ -0,0 +1,248 @@
+package apiquota
+
+import (
+	"context"
+	"cool-storage-api/util"
+	"expvar"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exported at /debug/vars
+var rejectedVar = expvar.NewInt("api_quota_rejected")
+
+// Finds the organization a token belongs to, found is false for unknown or expired tokens
+type OrganizationFunc func(ctx context.Context, token string) (orgId int, found bool)
+
+// Looks up the quotas set for single organizations, by organization
+type OverridesFunc func(ctx context.Context) (map[int]util.ApiQuota, error)
+
+// Adds calls and bytes to the counters of an organization for each period, returning the new totals
+type CountFunc func(ctx context.Context, orgId int, periods []string, calls int64, bytes int64) ([]util.ApiUsage, error)
+
+// How long the organization of a token and the per organization quotas are reused before they are looked up again
+const cacheTTL = time.Minute
+
+// tokens remembered at most, expired ones are dropped when it's reached
+const maxCachedTokens = 10000
+
+type cachedOrg struct {
+	orgId   int
+	expires time.Time
+}
+
+var (
+	mu           sync.RWMutex
+	defaults     util.ApiQuota
+	organization OrganizationFunc
+	loadOverride OverridesFunc
+	count        CountFunc
+
+	cacheMu          sync.Mutex
+	tokenOrgs        = map[string]cachedOrg{}
+	overrides        map[int]util.ApiQuota
+	overridesExpires time.Time
+)
+
+// Set the default quota of the organizations, where tokens are resolved to organizations, where per organization
+// quotas are read from and where usage is counted. Without an organization or count func nothing is limited.
+func Configure(d util.ApiQuota, o OrganizationFunc, q OverridesFunc, c CountFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaults = d
+	organization = o
+	loadOverride = q
+	count = c
+	Invalidate()
+}
+
+// Forget the cached organizations and quotas, e.g. after the quota of an organization changed
+func Invalidate() {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	tokenOrgs = map[string]cachedOrg{}
+	overrides = nil
+	overridesExpires = time.Time{}
+}
+
+// Quotas set for single organizations, read again once they are older than cacheTTL
+func orgOverrides(ctx context.Context, load OverridesFunc, now time.Time) map[int]util.ApiQuota {
+	if load == nil {
+		return nil
+	}
+	cacheMu.Lock()
+	if now.Before(overridesExpires) {
+		defer cacheMu.Unlock()
+		return overrides
+	}
+	cacheMu.Unlock()
+
+	loaded, err := load(ctx)
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if err != nil {
+		// keep using what was read last, and try again on the next request
+		log.Printf("could not read the API quotas of the organizations: %v", err)
+		return overrides
+	}
+	overrides = loaded
+	overridesExpires = now.Add(cacheTTL)
+	return overrides
+}
+
+// Organization of a token, remembered for cacheTTL. Unknown tokens aren't remembered,
+// so made up tokens can't fill the cache.
+func tokenOrganization(ctx context.Context, o OrganizationFunc, token string, now time.Time) (int, bool) {
+	cacheMu.Lock()
+	cached, ok := tokenOrgs[token]
+	cacheMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.orgId, true
+	}
+
+	orgId, found := o(ctx, token)
+	if !found {
+		return 0, false
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if len(tokenOrgs) >= maxCachedTokens {
+		for t, c := range tokenOrgs {
+			if !now.Before(c.expires) {
+				delete(tokenOrgs, t)
+			}
+		}
+		if len(tokenOrgs) >= maxCachedTokens {
+			tokenOrgs = map[string]cachedOrg{}
+		}
+	}
+	tokenOrgs[token] = cachedOrg{orgId: orgId, expires: now.Add(cacheTTL)}
+	return orgId, true
+}
+
+// Token a request is made with, from the Authorization header or the upload's user-token header
+func requestToken(r *http.Request) string {
+	if data := strings.Split(r.Header.Get("Authorization"), "Token "); len(data) == 2 {
+		return data[1]
+	}
+	return r.Header.Get("user-token")
+}
+
+// Day and month a time is counted in, UTC
+func periods(now time.Time) (day string, month string) {
+	now = now.UTC()
+	return now.Format("2006-01-02"), now.Format("2006-01")
+}
+
+// One quota window: its limits, what was used in it and when it starts over
+type window struct {
+	calls, bytes int64
+	usage        util.ApiUsage
+	reset        time.Time
+}
+
+func unlimited(q util.ApiQuota) bool {
+	return q.Daily_calls <= 0 && q.Daily_bytes <= 0 && q.Monthly_calls <= 0 && q.Monthly_bytes <= 0
+}
+
+func (w window) exceeded() bool {
+	return (w.calls > 0 && w.usage.Calls > w.calls) || (w.bytes > 0 && w.usage.Bytes >= w.bytes)
+}
+
+// Gin middleware counting the calls and bytes (request plus response bodies) of each organization,
+// rejecting its requests with 429 once its daily or monthly quota is used up.
+// Requests without a known token are left to the handlers, which reject them.
+func Middleware(c *gin.Context) {
+	mu.RLock()
+	quota, o, load, add := defaults, organization, loadOverride, count
+	mu.RUnlock()
+
+	token := requestToken(c.Request)
+	if token == "" || o == nil || add == nil {
+		c.Next()
+		return
+	}
+	ctx := c.Request.Context()
+	now := time.Now()
+	custom := orgOverrides(ctx, load, now)
+	if unlimited(quota) && len(custom) == 0 {
+		// no quota can apply, so the token isn't even looked up
+		c.Next()
+		return
+	}
+	orgId, found := tokenOrganization(ctx, o, token, now)
+	if !found {
+		c.Next()
+		return
+	}
+	if q, ok := custom[orgId]; ok {
+		quota = q
+	}
+	if unlimited(quota) {
+		c.Next()
+		return
+	}
+
+	// counted before the check, so concurrent requests can't slip past the limit
+	day, month := periods(now)
+	used, err := add(ctx, orgId, []string{day, month}, 1, 0)
+	if err != nil || len(used) != 2 {
+		log.Printf("could not count the API usage of organization %d: %v", orgId, err)
+		c.Next()
+		return
+	}
+	startOfDay := now.UTC().Truncate(24 * time.Hour)
+	windows := []window{
+		{quota.Daily_calls, quota.Daily_bytes, used[0], startOfDay.Add(24 * time.Hour)},
+		{quota.Monthly_calls, quota.Monthly_bytes, used[1], startOfDay.AddDate(0, 1, 1-startOfDay.Day())},
+	}
+
+	// headers describe the window with the fewest calls left
+	tightest := -1
+	for i, w := range windows {
+		if w.calls > 0 && (tightest < 0 || w.calls-w.usage.Calls < windows[tightest].calls-windows[tightest].usage.Calls) {
+			tightest = i
+		}
+	}
+	if tightest >= 0 {
+		w := windows[tightest]
+		remaining := w.calls - w.usage.Calls
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(w.calls, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(w.reset.Unix(), 10))
+	}
+	for i := len(windows) - 1; i >= 0; i-- {
+		if w := windows[i]; w.exceeded() {
+			rejectedVar.Add(1)
+			c.Header("Retry-After", strconv.Itoa(int(w.reset.Sub(now).Seconds())+1))
+			c.String(http.StatusTooManyRequests, "API quota exceeded")
+			c.Abort()
+			return
+		}
+	}
+
+	c.Next()
+
+	size := int64(c.Writer.Size())
+	if size < 0 {
+		size = 0
+	}
+	if c.Request.ContentLength > 0 {
+		size += c.Request.ContentLength
+	}
+	if size > 0 {
+		// the request may be cancelled by now, the bytes were transferred anyway
+		if _, err := add(context.Background(), orgId, []string{day, month}, 0, size); err != nil {
+			log.Printf("could not count the API usage of organization %d: %v", orgId, err)
+		}
+	}
+}
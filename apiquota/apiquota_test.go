@@ -0,0 +1,189 @@
+package apiquota_test
+
+import (
+	"context"
+	"cool-storage-api/apiquota"
+	"cool-storage-api/util"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tokens of two organizations, anything else is unknown
+func organization(ctx context.Context, token string) (int, bool) {
+	orgs := map[string]int{"first": 1, "first-bis": 1, "second": 2}
+	orgId, found := orgs[token]
+	return orgId, found
+}
+
+// In-memory counters standing in for the api_usage table
+func counter() (apiquota.CountFunc, map[int]map[string]util.ApiUsage) {
+	var mu sync.Mutex
+	used := map[int]map[string]util.ApiUsage{}
+	count := func(ctx context.Context, orgId int, periods []string, calls int64, bytes int64) ([]util.ApiUsage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if used[orgId] == nil {
+			used[orgId] = map[string]util.ApiUsage{}
+		}
+		totals := []util.ApiUsage{}
+		for _, period := range periods {
+			u := used[orgId][period]
+			u.Period = period
+			u.Calls += calls
+			u.Bytes += bytes
+			used[orgId][period] = u
+			totals = append(totals, u)
+		}
+		return totals, nil
+	}
+	return count, used
+}
+
+func router(body string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/info", apiquota.Middleware, func(c *gin.Context) {
+		c.String(http.StatusOK, body)
+	})
+	return r
+}
+
+func call(r *gin.Engine, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/info", nil)
+	req.Header.Set("Authorization", "Token "+token)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	count, used := counter()
+	apiquota.Configure(util.ApiQuota{Daily_calls: 2}, organization, nil, count)
+	defer apiquota.Configure(util.ApiQuota{}, nil, nil, nil)
+	r := router("success")
+
+	// tokens of the same organization share its quota
+	for i, token := range []string{"first", "first-bis"} {
+		w := call(r, token)
+		remaining := []string{"1", "0"}[i]
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != remaining {
+			t.Errorf("Expected %v,%v but got %v,%v", http.StatusOK, remaining, w.Code, w.Header().Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	w := call(r, "first")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected %v but got %v with headers %v", http.StatusTooManyRequests, w.Code, w.Header())
+	}
+
+	// every organization has its own quota
+	w = call(r, "second")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected %v but got %v", http.StatusOK, w.Code)
+	}
+
+	// unknown tokens are left to the handlers and not counted
+	for i := 0; i < 3; i++ {
+		call(r, "unknown")
+	}
+	if len(used) != 2 {
+		t.Errorf("Expected %v but got %v", 2, len(used))
+	}
+}
+
+func TestMiddlewareMonthly(t *testing.T) {
+	count, _ := counter()
+	apiquota.Configure(util.ApiQuota{Daily_calls: 5, Monthly_calls: 1}, organization, nil, count)
+	defer apiquota.Configure(util.ApiQuota{}, nil, nil, nil)
+	r := router("success")
+
+	w := call(r, "first")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("Expected %v,%v but got %v,%v", http.StatusOK, "1", w.Code, w.Header().Get("X-RateLimit-Limit"))
+	}
+	if w = call(r, "first"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected %v but got %v", http.StatusTooManyRequests, w.Code)
+	}
+}
+
+func TestMiddlewareOrgQuota(t *testing.T) {
+	count, _ := counter()
+	quota := func(ctx context.Context) (map[int]util.ApiQuota, error) {
+		return map[int]util.ApiQuota{2: {Daily_calls: 1}}, nil
+	}
+	apiquota.Configure(util.ApiQuota{Daily_calls: 3}, organization, quota, count)
+	defer apiquota.Configure(util.ApiQuota{}, nil, nil, nil)
+	r := router("success")
+
+	codes := []int{}
+	for _, token := range []string{"second", "second", "first", "first"} {
+		codes = append(codes, call(r, token).Code)
+	}
+	expected := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK}
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("Expected %v but got %v", expected, codes)
+			break
+		}
+	}
+}
+
+func TestMiddlewareBytes(t *testing.T) {
+	count, _ := counter()
+	apiquota.Configure(util.ApiQuota{Daily_bytes: 10}, organization, nil, count)
+	defer apiquota.Configure(util.ApiQuota{}, nil, nil, nil)
+	r := router("more than ten bytes")
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/info", nil)
+		req.Header.Set("user-token", "first")
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected %v but got %v", []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+	}
+}
+
+func TestMiddlewareLookups(t *testing.T) {
+	count, _ := counter()
+	lookups, loads := 0, 0
+	lookup := func(ctx context.Context, token string) (int, bool) {
+		lookups++
+		return organization(ctx, token)
+	}
+	custom := map[int]util.ApiQuota{}
+	load := func(ctx context.Context) (map[int]util.ApiQuota, error) {
+		loads++
+		return custom, nil
+	}
+	defer apiquota.Configure(util.ApiQuota{}, nil, nil, nil)
+	r := router("success")
+
+	// without a default quota nor any organization quota, tokens aren't looked up
+	apiquota.Configure(util.ApiQuota{}, lookup, load, count)
+	for i := 0; i < 3; i++ {
+		call(r, "first")
+	}
+	if lookups != 0 || loads != 1 {
+		t.Errorf("Expected %v,%v but got %v,%v", 0, 1, lookups, loads)
+	}
+
+	// the organization of a token is looked up once, and unknown tokens every time
+	custom[2] = util.ApiQuota{Daily_calls: 10}
+	apiquota.Configure(util.ApiQuota{}, lookup, load, count)
+	lookups, loads = 0, 0
+	for i := 0; i < 3; i++ {
+		call(r, "second")
+		call(r, "unknown")
+	}
+	if lookups != 4 || loads != 1 {
+		t.Errorf("Expected %v,%v but got %v,%v", 4, 1, lookups, loads)
+	}
+}
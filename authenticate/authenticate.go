@@ -1,6 +1,7 @@
 package authenticate
 
 import (
+	"context"
 	"cool-storage-api/dba"
 	"crypto/rand"
 	"database/sql"
@@ -14,7 +15,7 @@ import (
 )
 
 //Get the username associated with the token input
-func ValidateToken(authToken string) (map[string]interface{}, error) {
+func ValidateToken(ctx context.Context, authToken string) (map[string]interface{}, error) {
 
 	// db, err := sql.Open("mysql", "sample_db_user:EXAMPLE_PASSWORD@tcp(host.docker.internal:33061)/sample_db")
 	db, err := dba.ObtenerBaseDeDatos()
@@ -23,7 +24,7 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
 	}
 	defer db.Close()
 	// make sure connection is available
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +32,8 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
 	queryString := `select 
                 system_users.user_id,
                 email,
+                is_staff,
+                organization_org_id,
                 generated_at,
                 expires_at                         
             from authentication_tokens
@@ -38,7 +41,7 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
             on authentication_tokens.user_id = system_users.user_id
             where auth_token = ?`
 
-	stmt, err := db.Prepare(queryString)
+	stmt, err := db.PrepareContext(ctx, queryString)
 	if err != nil {
 		return nil, err
 	}
@@ -47,10 +50,12 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
 
 	userId := 0
 	email := ""
+	isStaff := sql.NullString{}
+	orgId := 0
 	generatedAt := ""
 	expiresAt := ""
 
-	err = stmt.QueryRow(authToken).Scan(&userId, &email, &generatedAt, &expiresAt)
+	err = stmt.QueryRowContext(ctx, authToken).Scan(&userId, &email, &isStaff, &orgId, &generatedAt, &expiresAt)
 
 	if err != nil {
 
@@ -73,6 +78,8 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
 	userDetails := map[string]interface{}{
 		"user_id":      userId,
 		"email":        email,
+		"is_staff":     isStaff.String == "yes",
+		"org_id":       orgId,
 		"generated_at": generatedAt,
 		"expires_at":   expiresAt,
 	}
@@ -81,7 +88,7 @@ func ValidateToken(authToken string) (map[string]interface{}, error) {
 }
 
 //Get a valid token associated with username and password
-func GetToken(email string, password string) (map[string]string, error) {
+func GetToken(ctx context.Context, email string, password string) (map[string]string, error) {
 
 	// db, err := sql.Open("mysql", "sample_db_user:EXAMPLE_PASSWORD@tcp(host.docker.internal:33061)/sample_db")
 	db, err := dba.ObtenerBaseDeDatos()
@@ -90,14 +97,14 @@ func GetToken(email string, password string) (map[string]string, error) {
 	}
 	defer db.Close()
 	// make sure connection is available
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	queryString := "select user_id, password from system_users where email = ?"
 
-	stmt, err := db.Prepare(queryString)
+	stmt, err := db.PrepareContext(ctx, queryString)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +114,7 @@ func GetToken(email string, password string) (map[string]string, error) {
 	userId := 0
 	accountPassword := ""
 
-	err = stmt.QueryRow(email).Scan(&userId, &accountPassword)
+	err = stmt.QueryRowContext(ctx, email).Scan(&userId, &accountPassword)
 
 	if err != nil {
 
@@ -126,7 +133,7 @@ func GetToken(email string, password string) (map[string]string, error) {
 
 	queryString = "select token_id, auth_token, generated_at, expires_at from authentication_tokens where user_id = ?"
 
-	stmt, err = db.Prepare(queryString)
+	stmt, err = db.PrepareContext(ctx, queryString)
 
 	if err != nil {
 		return nil, err
@@ -139,13 +146,13 @@ func GetToken(email string, password string) (map[string]string, error) {
 	generatedAt := ""
 	expiresAt := ""
 
-	err = stmt.QueryRow(userId).Scan(&token_id, &auth_token, &generatedAt, &expiresAt)
+	err = stmt.QueryRowContext(ctx, userId).Scan(&token_id, &auth_token, &generatedAt, &expiresAt)
 	if err != nil {
 
 		if err == sql.ErrNoRows {
 
 			queryString = "insert into authentication_tokens(user_id, auth_token, generated_at, expires_at) values (?, ?, ?, ?)"
-			stmt, err = db.Prepare(queryString)
+			stmt, err = db.PrepareContext(ctx, queryString)
 
 			if err != nil {
 				return nil, err
@@ -158,7 +165,7 @@ func GetToken(email string, password string) (map[string]string, error) {
 				return nil, err
 			}
 
-			_, err = stmt.Exec(userId, tokenDetails["auth_token"], tokenDetails["generated_at"], tokenDetails["expires_at"])
+			_, err = stmt.ExecContext(ctx, userId, tokenDetails["auth_token"], tokenDetails["generated_at"], tokenDetails["expires_at"])
 			if err != nil {
 				return nil, err
 			}
@@ -180,13 +187,13 @@ func GetToken(email string, password string) (map[string]string, error) {
 			return nil, err
 		}
 
-		sentenciaPreparada, err := db.Prepare("UPDATE authentication_tokens SET auth_token = ?, generated_at = ?, expires_at = ? WHERE token_id = ?")
+		sentenciaPreparada, err := db.PrepareContext(ctx, "UPDATE authentication_tokens SET auth_token = ?, generated_at = ?, expires_at = ? WHERE token_id = ?")
 		if err != nil {
 			return nil, err
 		}
 		defer sentenciaPreparada.Close()
 		// Pasar argumentos en el mismo orden que la consulta
-		_, err = sentenciaPreparada.Exec(tokenDetails["auth_token"], tokenDetails["generated_at"], tokenDetails["expires_at"], token_id)
+		_, err = sentenciaPreparada.ExecContext(ctx, tokenDetails["auth_token"], tokenDetails["generated_at"], tokenDetails["expires_at"], token_id)
 		if err != nil {
 			return nil, err
 		}
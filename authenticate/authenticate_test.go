@@ -1,6 +1,7 @@
 package authenticate_test
 
 import (
+	"context"
 	"cool-storage-api/authenticate"
 	"cool-storage-api/register"
 	"fmt"
@@ -13,13 +14,13 @@ import (
 func TestValidateToken_WithRandomUser(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	randomUser := strconv.Itoa(rand.Intn(1000000))
-	randomPassword := strconv.Itoa(rand.Intn(1000000))
+	randomPassword := "Test-Password-" + strconv.Itoa(rand.Intn(1000000))
 
-	register.RegisterUser(randomUser, randomPassword)
+	register.RegisterUser(context.Background(), randomUser, randomPassword)
 
-	tokenDetails, _ := authenticate.GetToken(randomUser, randomPassword)
+	tokenDetails, _ := authenticate.GetToken(context.Background(), randomUser, randomPassword)
 
-	userDetails, _ := authenticate.ValidateToken(tokenDetails["auth_token"])
+	userDetails, _ := authenticate.ValidateToken(context.Background(), tokenDetails["auth_token"])
 
 	username := fmt.Sprint(userDetails["username"])
 	if username != randomUser {
@@ -29,7 +30,7 @@ func TestValidateToken_WithRandomUser(t *testing.T) {
 }
 
 func TestValidateToken_WithNotValidToken(t *testing.T) {
-	_, err := authenticate.ValidateToken("authToken")
+	_, err := authenticate.ValidateToken(context.Background(), "authToken")
 	if err.Error() != "invalid access token" {
 		t.Errorf("Expected %v but got %v", "invalid access token", err.Error())
 	}
@@ -39,13 +40,13 @@ func TestGetToken_WithRandomUser(t *testing.T) {
 
 	rand.Seed(time.Now().UnixNano())
 	randomUser := strconv.Itoa(rand.Intn(1000000))
-	randomPassword := strconv.Itoa(rand.Intn(1000000))
+	randomPassword := "Test-Password-" + strconv.Itoa(rand.Intn(1000000))
 
-	register.RegisterUser(randomUser, randomPassword)
+	register.RegisterUser(context.Background(), randomUser, randomPassword)
 
-	tokenDetails, _ := authenticate.GetToken(randomUser, randomPassword)
+	tokenDetails, _ := authenticate.GetToken(context.Background(), randomUser, randomPassword)
 
-	userDetails, _ := authenticate.ValidateToken(tokenDetails["auth_token"])
+	userDetails, _ := authenticate.ValidateToken(context.Background(), tokenDetails["auth_token"])
 
 	username := fmt.Sprint(userDetails["username"])
 	if username != randomUser {
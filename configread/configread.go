@@ -7,22 +7,78 @@ import (
 )
 
 type Config struct {
-	CoolAppConf    AppConf  `yaml:"app"`
-	ServerConfig   ServConf `yaml:"server"`
-	DataBaseConfig DBConf   `yaml:"db"`
-	AWSConfig      AWSConf  `yaml:"aws"`
+	CoolAppConf     AppConf                    `yaml:"app"`
+	ServerConfig    ServConf                   `yaml:"server"`
+	DataBaseConfig  DBConf                     `yaml:"db"`
+	AWSConfig       AWSConf                    `yaml:"aws"`
+	DebugConfig     DebugConf                  `yaml:"debug"`
+	AccessLogConfig AccessLogConf              `yaml:"accessLog"`
+	PasswordPolicy  PasswordConf               `yaml:"passwordPolicy"`
+	FeatureFlags    map[string]FeatureFlagConf `yaml:"featureFlags"`
+	LicenseConfig   LicenseConf                `yaml:"license"`
 }
 
 type AppConf struct {
-	PrefixUrl       string `yaml:"prefixUrl"`
-	StorageSavePath string `yaml:"storageSavePath"`
+	PrefixUrl                string `yaml:"prefixUrl"`
+	StorageSavePath          string `yaml:"storageSavePath"`
+	UploadStagingTimeoutMins int    `yaml:"uploadStagingTimeoutMins"`
+	// when set, users can only register with an invite token
+	InviteOnly       bool `yaml:"inviteOnly"`
+	InviteExpiryDays int  `yaml:"inviteExpiryDays"`
+	// file with the SHA-256 of contents that are refused on upload, one per line
+	BlockedHashesFile string `yaml:"blockedHashesFile"`
+	// what to do with file names not in Unicode NFC form: "normalize" (default) or "reject"
+	UnicodePolicy string `yaml:"unicodePolicy"`
+}
+
+// Rules new passwords have to follow, the zero value accepts any non empty password
+type PasswordConf struct {
+	MinLength     int  `yaml:"minLength"`
+	RequireUpper  bool `yaml:"requireUpper"`
+	RequireLower  bool `yaml:"requireLower"`
+	RequireDigit  bool `yaml:"requireDigit"`
+	RequireSymbol bool `yaml:"requireSymbol"`
+	// file of SHA-1 hashes of breached passwords that are refused, one per line
+	BreachedHashesFile string `yaml:"breachedHashesFile"`
+}
+
+// Limits of the installation, 0 means no limit
+type LicenseConf struct {
+	MaxUsers int `yaml:"maxUsers"`
+	// bytes of all the uploaded files together
+	MaxStoredBytes int64 `yaml:"maxStoredBytes"`
+}
+
+// Rollout of a feature, organizations can still be switched on or off one by one by an admin
+type FeatureFlagConf struct {
+	Enabled bool `yaml:"enabled"`
+	// percentage of organizations that get the feature
+	Rollout int   `yaml:"rollout"`
+	Orgs    []int `yaml:"orgs"`
 }
 
 type ServConf struct {
-	Port             string `yaml:"port"`
-	TimeoutSecs      int    `yaml:"timeoutSecs"`
-	ReadTimeoutSecs  int    `yaml:"readTimeoutSecs"`
-	WriteTimeoutSecs int    `yaml:"writeTimeoutSecs"`
+	Port            string `yaml:"port"`
+	TimeoutSecs     int    `yaml:"timeoutSecs"`
+	ReadTimeoutSecs int    `yaml:"readTimeoutSecs"`
+	// per route deadlines overriding TimeoutSecs, keyed by route path, 0 disables the deadline
+	RouteTimeoutSecs map[string]int `yaml:"routeTimeoutSecs"`
+	// time in-flight requests get to finish on SIGTERM before the server exits
+	ShutdownTimeoutSecs int `yaml:"shutdownTimeoutSecs"`
+	// name of this instance in restore_jobs, the host name when empty
+	InstanceId string `yaml:"instanceId"`
+	// requests and storage calls slower than this are logged, 0 disables it
+	SlowThresholdMillis int `yaml:"slowThresholdMillis"`
+	// uploads are rejected with 503 once this many bytes are being received, 0 disables it
+	MaxUploadBytesInFlight int64 `yaml:"maxUploadBytesInFlight"`
+	// bytes counted for an upload sent without Content-Length, 0 uses the default of 64 MB
+	UnknownUploadSizeBytes int64 `yaml:"unknownUploadSizeBytes"`
+	UploadRetryAfterSecs   int   `yaml:"uploadRetryAfterSecs"`
+	// calls and request plus response bytes each organization may use per day and month, 0 disables a quota
+	DailyCallQuota    int64 `yaml:"dailyCallQuota"`
+	DailyBytesQuota   int64 `yaml:"dailyBytesQuota"`
+	MonthlyCallQuota  int64 `yaml:"monthlyCallQuota"`
+	MonthlyBytesQuota int64 `yaml:"monthlyBytesQuota"`
 }
 
 type DBConf struct {
@@ -54,6 +110,38 @@ type AWSConf struct {
 	VaultName       string `yaml:"vaultName"`
 	SNSTopicName    string `yaml:"snstopicName"`
 	SNSTopic        string `yaml:"SNSTopic"`
+	// pricing of each Glacier retrieval tier, keyed by tier name
+	RestorePricing map[string]RestorePrice `yaml:"restorePricing"`
+	// restores estimated above this cost must be confirmed explicitly
+	RestoreConfirmThreshold float64 `yaml:"restoreConfirmThreshold"`
+}
+
+type RestorePrice struct {
+	PerGB      float64 `yaml:"perGB"`
+	PerRequest float64 `yaml:"perRequest"`
+	ReadyHours int     `yaml:"readyHours"`
+}
+
+type DebugConf struct {
+	// serve /debug/pprof and /debug/vars to staff users
+	EnablePprof bool `yaml:"enablePprof"`
+	// faults injected into AWS calls by operation name, for staging and integration tests only
+	AWSFaults map[string]AWSFaultConf `yaml:"awsFaults"`
+}
+
+type AWSFaultConf struct {
+	LatencyMillis int     `yaml:"latencyMillis"`
+	ErrorRate     float64 `yaml:"errorRate"`
+}
+
+type AccessLogConf struct {
+	Enable bool   `yaml:"enable"`
+	Path   string `yaml:"path"`
+	// "json" or "w3c"
+	Format        string `yaml:"format"`
+	MaxSizeMB     int64  `yaml:"maxSizeMB"`
+	MaxAgeHours   int    `yaml:"maxAgeHours"`
+	SyslogAddress string `yaml:"syslogAddress"`
 }
 
 func unmarshalYAMLFile(path string, v interface{}) error {
@@ -1,12 +1,14 @@
 package dba
 
 import (
+	"context"
 	"cool-storage-api/configread"
 	"cool-storage-api/util"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -116,20 +118,20 @@ func InsertIntoAuthenticationTokens() (e error) {
 	return nil
 }
 
-func InsertArchive(a util.Archive) (e error) {
+func InsertArchive(ctx context.Context, a util.Archive) (e error) {
 	db, err := ObtenerBaseDeDatos()
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	sql, err := db.Prepare("INSERT INTO files (`vault_file_id`,`library_id`,`user_id`,`file_name`,`upload_date`,`file_size`, `file_checksum`, `file_state`) VALUES(?, ?, ?, ?, ?, ?, ?, ?)")
+	sql, err := db.PrepareContext(ctx, "INSERT INTO files (`vault_file_id`,`library_id`,`user_id`,`file_name`,`upload_date`,`file_size`, `file_checksum`, `file_sha256`, `file_state`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 	defer sql.Close()
 
-	_, err = sql.Exec(a.Vault_file_id, a.Library_id, a.User_id, a.File_name, a.Upload_date, a.File_size, a.File_checksum, a.File_state)
+	_, err = sql.ExecContext(ctx, a.Vault_file_id, a.Library_id, a.User_id, a.File_name, a.Upload_date, a.File_size, a.File_checksum, a.File_sha256, a.File_state)
 	if err != nil {
 		return err
 	}
@@ -137,7 +139,7 @@ func InsertArchive(a util.Archive) (e error) {
 	return nil
 }
 
-func GetArchive(id string) (util.Archive, error) {
+func GetArchive(ctx context.Context, id string) (util.Archive, error) {
 	arc := util.Archive{}
 	db, err := ObtenerBaseDeDatos()
 	if err != nil {
@@ -145,9 +147,9 @@ func GetArchive(id string) (util.Archive, error) {
 	}
 	defer db.Close()
 
-	sqlQuery := "SELECT * FROM files where vault_file_id=?"
-	row := db.QueryRow(sqlQuery, id)
-	err1 := row.Scan(&arc.Vault_file_id, &arc.Library_id, &arc.User_id, &arc.File_name, &arc.Upload_date, &arc.File_size, &arc.File_checksum, &arc.File_state)
+	sqlQuery := "SELECT vault_file_id, library_id, user_id, file_name, upload_date, file_size, file_checksum, file_sha256, file_state FROM files where vault_file_id=?"
+	row := db.QueryRowContext(ctx, sqlQuery, id)
+	err1 := row.Scan(&arc.Vault_file_id, &arc.Library_id, &arc.User_id, &arc.File_name, &arc.Upload_date, &arc.File_size, &arc.File_checksum, &arc.File_sha256, &arc.File_state)
 	return arc, err1
 }
 
@@ -183,3 +185,657 @@ func BuildRandomToken() (map[string]string, error) {
 
 	return tokenDetails, err
 }
+
+func GetUserOrganization(ctx context.Context, userId int) (int, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	orgId := 0
+	err = db.QueryRowContext(ctx, "SELECT organization_org_id FROM system_users WHERE user_id = ?", userId).Scan(&orgId)
+	return orgId, err
+}
+
+// Get the monthly restore budget of an organization (not valid when unlimited) and what it spent in period
+func GetRestoreBudget(ctx context.Context, orgId int, period string) (budget sql.NullFloat64, spent float64, e error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return budget, 0, err
+	}
+	defer db.Close()
+
+	sqlQuery := `SELECT organization.restore_budget, COALESCE(restore_spending.cost, 0)
+            FROM organization
+            LEFT JOIN restore_spending
+            ON restore_spending.org_id = organization.org_id AND restore_spending.period = ?
+            WHERE organization.org_id = ?`
+	err = db.QueryRowContext(ctx, sqlQuery, period, orgId).Scan(&budget, &spent)
+	return budget, spent, err
+}
+
+func SetRestoreBudget(ctx context.Context, orgId int, budget sql.NullFloat64) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "UPDATE organization SET restore_budget = ? WHERE org_id = ?", budget, orgId)
+	return err
+}
+
+// Add the estimated cost of a restore to what an organization spent in period
+func AddRestoreSpending(ctx context.Context, orgId int, period string, cost float64) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sentenciaPreparada, err := db.PrepareContext(ctx, "INSERT INTO restore_spending (org_id, period, cost) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE cost = cost + VALUES(cost)")
+	if err != nil {
+		return err
+	}
+	defer sentenciaPreparada.Close()
+
+	_, err = sentenciaPreparada.ExecContext(ctx, orgId, period, cost)
+	return err
+}
+
+// Add the cost of a restore to what an organization spent in period unless it would go over its budget,
+// reporting whether the spending was recorded
+func ReserveRestoreSpending(ctx context.Context, orgId int, period string, cost float64) (bool, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// the organization row stays locked until commit, so concurrent restores are checked one after the other
+	budget := sql.NullFloat64{}
+	err = tx.QueryRowContext(ctx, "SELECT restore_budget FROM organization WHERE org_id = ? FOR UPDATE", orgId).Scan(&budget)
+	if err != nil {
+		return false, err
+	}
+	spent := 0.0
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(cost), 0) FROM restore_spending WHERE org_id = ? AND period = ?", orgId, period).Scan(&spent)
+	if err != nil {
+		return false, err
+	}
+	if budget.Valid && spent+cost > budget.Float64 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO restore_spending (org_id, period, cost) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE cost = cost + VALUES(cost)", orgId, period, cost)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func InsertNotification(ctx context.Context, n util.Notification) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sentenciaPreparada, err := db.PrepareContext(ctx, "INSERT INTO notifications (user_id, type, message, archive_id, created_at, seen) VALUES(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer sentenciaPreparada.Close()
+
+	_, err = sentenciaPreparada.ExecContext(ctx, n.User_id, n.Type, n.Message, n.Archive_id, time.Now().Format("2006-01-02 15:04:05"), false)
+	return err
+}
+
+// Get the latest notifications of a user, newest first
+func GetNotifications(ctx context.Context, userId int, limit int) ([]util.Notification, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sqlQuery := "SELECT notification_id, user_id, type, message, archive_id, created_at, seen FROM notifications WHERE user_id = ? ORDER BY notification_id DESC LIMIT ?"
+	rows, err := db.QueryContext(ctx, sqlQuery, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []util.Notification{}
+	for rows.Next() {
+		n := util.Notification{}
+		err := rows.Scan(&n.Notification_id, &n.User_id, &n.Type, &n.Message, &n.Archive_id, &n.Created_at, &n.Seen)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func CountUnreadNotifications(ctx context.Context, userId int) (int, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	count := 0
+	err = db.QueryRowContext(ctx, "SELECT COUNT(notification_id) FROM notifications WHERE user_id = ? AND seen = false", userId).Scan(&count)
+	return count, err
+}
+
+// Mark a notification of a user as read, or all of them when notificationId is 0
+func MarkNotificationsSeen(ctx context.Context, userId int, notificationId int) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if notificationId == 0 {
+		_, err = db.ExecContext(ctx, "UPDATE notifications SET seen = true WHERE user_id = ?", userId)
+		return err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE notifications SET seen = true WHERE user_id = ? AND notification_id = ?", userId, notificationId)
+	return err
+}
+
+// Find which of the given SHA-256 hashes belong to archives a user already uploaded,
+// mapping each known hash to the id of its archive
+func FindArchivesByHash(ctx context.Context, userId int, hashes []string) (map[string]string, error) {
+	found := map[string]string{}
+	if len(hashes) == 0 {
+		return found, nil
+	}
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	args := []interface{}{userId}
+	for _, hash := range hashes {
+		args = append(args, hash)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(hashes)), ",")
+	sqlQuery := "SELECT file_sha256, vault_file_id FROM files WHERE user_id = ? AND file_state = 'uploaded' AND file_sha256 IN (" + placeholders + ")"
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash, archiveId string
+		if err := rows.Scan(&hash, &archiveId); err != nil {
+			return nil, err
+		}
+		found[hash] = archiveId
+	}
+	return found, rows.Err()
+}
+
+func InsertInvitation(ctx context.Context, inv util.Invitation) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sentenciaPreparada, err := db.PrepareContext(ctx, "INSERT INTO invitations (invite_token, org_id, email_domain, quota_total, created_by, expires_at, max_uses) VALUES(?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer sentenciaPreparada.Close()
+
+	_, err = sentenciaPreparada.ExecContext(ctx, inv.Invite_token, inv.Org_id, inv.Email_domain, inv.Quota_total, inv.Created_by, inv.Expires_at, inv.Max_uses)
+	return err
+}
+
+func GetInvitation(ctx context.Context, token string) (util.Invitation, error) {
+	inv := util.Invitation{}
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return inv, err
+	}
+	defer db.Close()
+
+	sqlQuery := "SELECT invite_token, org_id, email_domain, quota_total, created_by, expires_at, max_uses, use_count FROM invitations WHERE invite_token = ?"
+	err = db.QueryRowContext(ctx, sqlQuery, token).Scan(&inv.Invite_token, &inv.Org_id, &inv.Email_domain, &inv.Quota_total, &inv.Created_by, &inv.Expires_at, &inv.Max_uses, &inv.Use_count)
+	return inv, err
+}
+
+// Revoke an invitation of an organization, reporting whether it existed
+func DeleteInvitation(ctx context.Context, orgId int, token string) (bool, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	res, err := db.ExecContext(ctx, "DELETE FROM invitations WHERE org_id = ? AND invite_token = ?", orgId, token)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
+
+// Whether a feature was explicitly turned on or off for an organization
+func GetFeatureFlagOverride(ctx context.Context, name string, orgId int) (enabled bool, found bool, err error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return false, false, err
+	}
+	defer db.Close()
+
+	err = db.QueryRowContext(ctx, "SELECT enabled FROM feature_flags WHERE org_id = ? AND flag = ?", orgId, name).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	return enabled, err == nil, err
+}
+
+// Turn a feature on or off for an organization, a null value goes back to the configured rollout
+func SetFeatureFlagOverride(ctx context.Context, name string, orgId int, enabled sql.NullBool) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if !enabled.Valid {
+		_, err = db.ExecContext(ctx, "DELETE FROM feature_flags WHERE org_id = ? AND flag = ?", orgId, name)
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO feature_flags (org_id, flag, enabled) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE enabled = VALUES(enabled)", orgId, name, enabled.Bool)
+	return err
+}
+
+// API quota set for an organization, found is false when it uses the configured defaults
+func GetApiQuota(ctx context.Context, orgId int) (quota util.ApiQuota, found bool, err error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return quota, false, err
+	}
+	defer db.Close()
+
+	err = db.QueryRowContext(ctx, "SELECT daily_calls, daily_bytes, monthly_calls, monthly_bytes FROM api_quotas WHERE org_id = ?", orgId).
+		Scan(&quota.Daily_calls, &quota.Daily_bytes, &quota.Monthly_calls, &quota.Monthly_bytes)
+	if err == sql.ErrNoRows {
+		return quota, false, nil
+	}
+	return quota, err == nil, err
+}
+
+// API quotas set for single organizations, by organization
+func GetApiQuotas(ctx context.Context) (map[int]util.ApiQuota, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT org_id, daily_calls, daily_bytes, monthly_calls, monthly_bytes FROM api_quotas")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := map[int]util.ApiQuota{}
+	for rows.Next() {
+		var orgId int
+		quota := util.ApiQuota{}
+		if err := rows.Scan(&orgId, &quota.Daily_calls, &quota.Daily_bytes, &quota.Monthly_calls, &quota.Monthly_bytes); err != nil {
+			return nil, err
+		}
+		quotas[orgId] = quota
+	}
+	return quotas, rows.Err()
+}
+
+func SetApiQuota(ctx context.Context, orgId int, quota util.ApiQuota) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "INSERT INTO api_quotas (org_id, daily_calls, daily_bytes, monthly_calls, monthly_bytes) VALUES(?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE daily_calls = VALUES(daily_calls), daily_bytes = VALUES(daily_bytes), monthly_calls = VALUES(monthly_calls), monthly_bytes = VALUES(monthly_bytes)",
+		orgId, quota.Daily_calls, quota.Daily_bytes, quota.Monthly_calls, quota.Monthly_bytes)
+	return err
+}
+
+// Go back to the configured default API quota for an organization
+func DeleteApiQuota(ctx context.Context, orgId int) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "DELETE FROM api_quotas WHERE org_id = ?", orgId)
+	return err
+}
+
+// Add calls and bytes to the API usage of an organization in each period, returning the new totals in the same order
+func AddApiUsage(ctx context.Context, orgId int, periods []string, calls int64, bytes int64) ([]util.ApiUsage, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	used := make([]util.ApiUsage, 0, len(periods))
+	for _, period := range periods {
+		_, err = tx.ExecContext(ctx, "INSERT INTO api_usage (org_id, period, calls, bytes) VALUES(?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE calls = calls + VALUES(calls), bytes = bytes + VALUES(bytes)", orgId, period, calls, bytes)
+		if err != nil {
+			return nil, err
+		}
+		u := util.ApiUsage{Period: period}
+		err = tx.QueryRowContext(ctx, "SELECT calls, bytes FROM api_usage WHERE org_id = ? AND period = ?", orgId, period).Scan(&u.Calls, &u.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		used = append(used, u)
+	}
+	return used, tx.Commit()
+}
+
+// API usage of an organization in the periods it used the API in
+func GetApiUsage(ctx context.Context, orgId int, periods []string) ([]util.ApiUsage, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	used := []util.ApiUsage{}
+	for _, period := range periods {
+		u := util.ApiUsage{Period: period}
+		err = db.QueryRowContext(ctx, "SELECT calls, bytes FROM api_usage WHERE org_id = ? AND period = ?", orgId, period).Scan(&u.Calls, &u.Bytes)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		used = append(used, u)
+	}
+	return used, nil
+}
+
+// Archives of a user whose content was uploaded more than once, ordered by hash and upload date
+func GetDuplicateArchives(ctx context.Context, userId int) ([]util.Archive, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sqlQuery := "SELECT vault_file_id, library_id, user_id, file_name, upload_date, file_size, file_checksum, file_sha256, file_state FROM files " +
+		"WHERE user_id = ? AND file_state = 'uploaded' AND file_sha256 IN " +
+		"(SELECT file_sha256 FROM files WHERE user_id = ? AND file_state = 'uploaded' AND file_sha256 <> '' GROUP BY file_sha256 HAVING COUNT(*) > 1) " +
+		"ORDER BY file_sha256, upload_date"
+	rows, err := db.QueryContext(ctx, sqlQuery, userId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	archives := []util.Archive{}
+	for rows.Next() {
+		a := util.Archive{}
+		err := rows.Scan(&a.Vault_file_id, &a.Library_id, &a.User_id, &a.File_name, &a.Upload_date, &a.File_size, &a.File_checksum, &a.File_sha256, &a.File_state)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// Usage of every organization with archives or restores in period, a day or a month running from from until to:
+// the archives it holds at the end of the period (or now, for the current one), the storage they took
+// during the period, and the bytes and cost of the restores started in it
+func GetOrgUsage(ctx context.Context, period string, from time.Time, to time.Time) ([]util.OrgUsage, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	usage := map[int]*util.OrgUsage{}
+	orgUsage := func(orgId int) *util.OrgUsage {
+		if _, ok := usage[orgId]; !ok {
+			usage[orgId] = &util.OrgUsage{Org_id: orgId, Period: period}
+		}
+		return usage[orgId]
+	}
+
+	const timeLayout = "2006-01-02 15:04:05"
+	end := to
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+
+	// archives are never deleted, so everything uploaded before the end of the period was held until then.
+	// Sizes are stored formatted, so they are added up here rather than in SQL.
+	sqlQuery := "SELECT system_users.organization_org_id, files.file_size, files.upload_date FROM files JOIN system_users ON system_users.user_id = files.user_id " +
+		"WHERE files.file_state = 'uploaded' AND files.upload_date < ?"
+	rows, err := db.QueryContext(ctx, sqlQuery, end.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orgId int
+		var fileSize, uploadDate string
+		if err := rows.Scan(&orgId, &fileSize, &uploadDate); err != nil {
+			return nil, err
+		}
+		size, _ := util.ParseHumanFileSize(fileSize)
+		uploaded, err := time.ParseInLocation(timeLayout, uploadDate, time.Local)
+		if err != nil {
+			uploaded = from
+		}
+		u := orgUsage(orgId)
+		u.Archives++
+		u.Stored_bytes += size
+		u.Stored_gib_days += size / (1 << 30) * util.HeldDays(uploaded, from, end)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sqlQuery = "SELECT org_id, COALESCE(SUM(bytes), 0), COALESCE(SUM(cost), 0) FROM restore_jobs WHERE created_at >= ? AND created_at < ? GROUP BY org_id"
+	restores, err := db.QueryContext(ctx, sqlQuery, from.Format(timeLayout), to.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer restores.Close()
+	for restores.Next() {
+		var orgId int
+		var bytes int64
+		var cost float64
+		if err := restores.Scan(&orgId, &bytes, &cost); err != nil {
+			return nil, err
+		}
+		u := orgUsage(orgId)
+		u.Restored_bytes = bytes
+		u.Restore_cost = cost
+	}
+	if err := restores.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []util.OrgUsage{}
+	for _, u := range usage {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Org_id < result[j].Org_id })
+	return result, nil
+}
+
+func InsertRestoreJob(ctx context.Context, job util.RestoreJob) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "INSERT INTO restore_jobs (job_id, archive_id, user_id, org_id, status, cost, bytes, created_at, owner) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		job.Job_id, job.Archive_id, job.User_id, job.Org_id, job.Status, job.Cost, job.Bytes, job.Created_at, sql.NullString{String: job.Owner, Valid: job.Owner != ""})
+	return err
+}
+
+const restoreJobColumns = "job_id, archive_id, user_id, org_id, status, cost, bytes, created_at, completed_at, owner"
+
+func scanRestoreJob(row interface{ Scan(...interface{}) error }) (util.RestoreJob, error) {
+	job := util.RestoreJob{}
+	completedAt := sql.NullString{}
+	owner := sql.NullString{}
+	err := row.Scan(&job.Job_id, &job.Archive_id, &job.User_id, &job.Org_id, &job.Status, &job.Cost, &job.Bytes, &job.Created_at, &completedAt, &owner)
+	job.Completed_at = completedAt.String
+	job.Owner = owner.String
+	return job, err
+}
+
+// Bytes of all the uploaded files together
+func GetStoredBytes(ctx context.Context) (float64, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// sizes are stored formatted, so they are added up here rather than in SQL
+	rows, err := db.QueryContext(ctx, "SELECT file_size FROM files WHERE file_state = 'uploaded'")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var stored float64
+	for rows.Next() {
+		var fileSize string
+		if err := rows.Scan(&fileSize); err != nil {
+			return 0, err
+		}
+		size, _ := util.ParseHumanFileSize(fileSize)
+		stored += size
+	}
+	return stored, rows.Err()
+}
+
+// Make owner the instance waiting for a restore job in progress nobody waits for,
+// false when another instance claimed it first
+func ClaimRestoreJob(ctx context.Context, jobId string, owner string) (bool, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	res, err := db.ExecContext(ctx, "UPDATE restore_jobs SET owner = ? WHERE job_id = ? AND status = 'in_progress' AND owner IS NULL", owner, jobId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Let other instances claim the restore jobs owner was still waiting for
+func ReleaseRestoreJobs(ctx context.Context, owner string) (int64, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	res, err := db.ExecContext(ctx, "UPDATE restore_jobs SET owner = NULL WHERE owner = ? AND status = 'in_progress'", owner)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func GetRestoreJob(ctx context.Context, jobId string) (util.RestoreJob, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return util.RestoreJob{}, err
+	}
+	defer db.Close()
+
+	return scanRestoreJob(db.QueryRowContext(ctx, "SELECT "+restoreJobColumns+" FROM restore_jobs WHERE job_id = ?", jobId))
+}
+
+// Latest restore of an archive that is still in progress, sql.ErrNoRows when there is none
+func GetActiveRestoreJob(ctx context.Context, archiveId string) (util.RestoreJob, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return util.RestoreJob{}, err
+	}
+	defer db.Close()
+
+	sqlQuery := "SELECT " + restoreJobColumns + " FROM restore_jobs WHERE archive_id = ? AND status = 'in_progress' ORDER BY created_at DESC LIMIT 1"
+	return scanRestoreJob(db.QueryRowContext(ctx, sqlQuery, archiveId))
+}
+
+// Restore jobs in a status, oldest first
+func GetRestoreJobsByStatus(ctx context.Context, status string) ([]util.RestoreJob, error) {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT "+restoreJobColumns+" FROM restore_jobs WHERE status = ? ORDER BY created_at", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []util.RestoreJob{}
+	for rows.Next() {
+		job, err := scanRestoreJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Record that a restore job completed or failed
+func FinishRestoreJob(ctx context.Context, jobId string, status string) error {
+	db, err := ObtenerBaseDeDatos()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "UPDATE restore_jobs SET status = ?, completed_at = ? WHERE job_id = ?", status, time.Now().Format("2006-01-02 15:04:05"), jobId)
+	return err
+}
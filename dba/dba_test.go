@@ -1,7 +1,10 @@
 package dba_test
 
 import (
+	"context"
 	"cool-storage-api/dba"
+	"cool-storage-api/util"
+	"fmt"
 	"math/rand"
 	"strconv"
 	"testing"
@@ -58,3 +61,39 @@ func TestInsertIntoAuthenticationTokens(t *testing.T) {
 		t.Errorf("Expected %v but got %v", nil, err)
 	}
 }
+
+func TestClaimRestoreJob(t *testing.T) {
+	ctx := context.Background()
+	job := util.RestoreJob{
+		Job_id:     fmt.Sprintf("test-job-%d", rand.Int63()),
+		Archive_id: "test-archive",
+		User_id:    1,
+		Org_id:     1,
+		Status:     "in_progress",
+		Created_at: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := dba.InsertRestoreJob(ctx, job); err != nil {
+		t.Fatalf("Expected %v but got %v", nil, err)
+	}
+
+	// only one of the instances starting together gets the job
+	claimed, err := dba.ClaimRestoreJob(ctx, job.Job_id, "instance-a")
+	if !claimed || err != nil {
+		t.Errorf("Expected %v,%v but got %v,%v", true, nil, claimed, err)
+	}
+	claimed, err = dba.ClaimRestoreJob(ctx, job.Job_id, "instance-b")
+	if claimed || err != nil {
+		t.Errorf("Expected %v,%v but got %v,%v", false, nil, claimed, err)
+	}
+
+	// once released it can be claimed again
+	released, err := dba.ReleaseRestoreJobs(ctx, "instance-a")
+	if released != 1 || err != nil {
+		t.Errorf("Expected %v,%v but got %v,%v", 1, nil, released, err)
+	}
+	claimed, err = dba.ClaimRestoreJob(ctx, job.Job_id, "instance-b")
+	if !claimed || err != nil {
+		t.Errorf("Expected %v,%v but got %v,%v", true, nil, claimed, err)
+	}
+	dba.FinishRestoreJob(ctx, job.Job_id, "failed")
+}
@@ -0,0 +1,91 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+)
+
+// How a feature is rolled out, before per organization overrides
+type Flag struct {
+	// on for every organization
+	Enabled bool
+	// percentage of organizations the feature is on for, picked by a stable hash
+	Rollout int
+	// organizations the feature is always on for
+	Orgs []int
+}
+
+// Looks up whether a feature was explicitly turned on or off for an organization
+type OverrideFunc func(ctx context.Context, name string, orgId int) (enabled bool, found bool, err error)
+
+var (
+	mu       sync.RWMutex
+	flags    = map[string]Flag{}
+	override OverrideFunc
+)
+
+// Set the known flags and where organization overrides are read from, nil for none
+func Configure(f map[string]Flag, o OverrideFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	flags = f
+	if flags == nil {
+		flags = map[string]Flag{}
+	}
+	override = o
+}
+
+// Names of the known flags, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Whether a feature is on for an organization. Unknown features are off, and
+// a failing override lookup falls back to the configured rollout.
+func Enabled(ctx context.Context, name string, orgId int) bool {
+	mu.RLock()
+	flag, known := flags[name]
+	o := override
+	mu.RUnlock()
+	if !known {
+		return false
+	}
+
+	if o != nil {
+		enabled, found, err := o(ctx, name, orgId)
+		if err != nil {
+			log.Printf("could not read feature flag %s of organization %d: %v", name, orgId, err)
+		} else if found {
+			return enabled
+		}
+	}
+
+	if flag.Enabled {
+		return true
+	}
+	for _, id := range flag.Orgs {
+		if id == orgId {
+			return true
+		}
+	}
+	return bucket(name, orgId) < flag.Rollout
+}
+
+// Stable bucket from 0 to 99 of an organization for a feature, so growing the
+// rollout keeps the organizations that already had it
+func bucket(name string, orgId int) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", name, orgId)
+	return int(h.Sum32() % 100)
+}
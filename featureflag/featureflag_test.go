@@ -0,0 +1,63 @@
+package featureflag_test
+
+import (
+	"context"
+	"cool-storage-api/featureflag"
+	"errors"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	overrides := map[int]bool{3: false, 4: true}
+	featureflag.Configure(map[string]featureflag.Flag{
+		"everyone": {Enabled: true},
+		"listed":   {Orgs: []int{2}},
+		"nobody":   {},
+	}, func(ctx context.Context, name string, orgId int) (bool, bool, error) {
+		if orgId == 5 {
+			return false, false, errors.New("database down")
+		}
+		enabled, found := overrides[orgId]
+		return enabled, found, nil
+	})
+	defer featureflag.Configure(nil, nil)
+
+	cases := []struct {
+		name        string
+		orgId       int
+		expectation bool
+	}{
+		{"everyone", 1, true},
+		{"everyone", 3, false},
+		{"listed", 1, false},
+		{"listed", 2, true},
+		{"nobody", 4, true},
+		{"nobody", 5, false},
+		{"everyone", 5, true},
+		{"unknown", 4, false},
+	}
+	for _, tc := range cases {
+		got := featureflag.Enabled(context.Background(), tc.name, tc.orgId)
+		if got != tc.expectation {
+			t.Errorf("Expected %v but got %v for %s of organization %d", tc.expectation, got, tc.name, tc.orgId)
+		}
+	}
+}
+
+func TestEnabledRollout(t *testing.T) {
+	featureflag.Configure(map[string]featureflag.Flag{"half": {Rollout: 50}, "all": {Rollout: 100}}, nil)
+	defer featureflag.Configure(nil, nil)
+
+	half := 0
+	for orgId := 1; orgId <= 1000; orgId++ {
+		if featureflag.Enabled(context.Background(), "half", orgId) {
+			half++
+		}
+		if !featureflag.Enabled(context.Background(), "all", orgId) {
+			t.Errorf("Expected %v but got %v for organization %d", true, false, orgId)
+		}
+	}
+	if half < 400 || half > 600 {
+		t.Errorf("Expected about %v but got %v", 500, half)
+	}
+}
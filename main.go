@@ -1,15 +1,33 @@
 package main
 
 import (
+	"context"
+	"cool-storage-api/accesslog"
+	"cool-storage-api/apiquota"
 	"cool-storage-api/authenticate"
 	"cool-storage-api/configread"
 	"cool-storage-api/dba"
+	"cool-storage-api/featureflag"
 	"cool-storage-api/plugins/glacierManager"
 	"cool-storage-api/register"
+	"cool-storage-api/slowlog"
+	"cool-storage-api/transferstats"
+	"cool-storage-api/uploadlimit"
+	"cool-storage-api/util"
+	"database/sql"
 	"errors"
+	"expvar"
 	"fmt"
+	"log"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -19,28 +37,136 @@ func main() {
 
 	config := configread.Configuration
 
+	slowlog.SetThreshold(time.Duration(config.ServerConfig.SlowThresholdMillis) * time.Millisecond)
+	uploadlimit.Configure(config.ServerConfig.MaxUploadBytesInFlight, config.ServerConfig.UnknownUploadSizeBytes, time.Duration(config.ServerConfig.UploadRetryAfterSecs)*time.Second)
+	apiquota.Configure(apiQuotaDefaults(), quotaOrganization, dba.GetApiQuotas, dba.AddApiUsage)
+	flags := map[string]featureflag.Flag{}
+	for name, flag := range config.FeatureFlags {
+		flags[name] = featureflag.Flag{Enabled: flag.Enabled, Rollout: flag.Rollout, Orgs: flag.Orgs}
+	}
+	featureflag.Configure(flags, dba.GetFeatureFlagOverride)
+
 	r := gin.Default()
 	r.MaxMultipartMemory = 8 << 20
+	r.Use(slowlog.Middleware)
+	if config.AccessLogConfig.Enable {
+		accessLog, err := accesslog.New(accesslog.Options{
+			Path:          config.AccessLogConfig.Path,
+			Format:        config.AccessLogConfig.Format,
+			MaxSize:       config.AccessLogConfig.MaxSizeMB << 20,
+			MaxAge:        time.Duration(config.AccessLogConfig.MaxAgeHours) * time.Hour,
+			SyslogAddress: config.AccessLogConfig.SyslogAddress,
+		})
+		if err != nil {
+			panic(err)
+		}
+		r.Use(accessLog)
+	}
 	r.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
-		AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "OPTIONS"},
+		AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"authorization", "uploader-chunk-number", "uploader-chunks-total", "uploader-file-id", "uploader-file-name", "uploader-file-hash"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           86400,
 	}))
+	// after cors, so preflights are answered without using quota and 429 answers still carry the cors headers
+	r.Use(apiquota.Middleware)
+
+	timeout := withTimeout(time.Duration(config.ServerConfig.TimeoutSecs)*time.Second, config.ServerConfig.RouteTimeoutSecs)
+
+	r.GET("/api/v1/ping", timeout, PingResponse)
+	r.GET("/api/v1/server-features", timeout, ServerFeaturesResponse)
+	r.POST("/api/v1/auth-token", timeout, GetAuthenticationTokenHandler)
+	r.GET("/api/v1/auth/ping", timeout, AuthPing)
+	r.POST("/api/v1/registrations", timeout, RegistrationsHandler)
+	r.GET("/api/v1/account/info", timeout, AccountInfoResponse)
+	// long transfer: no deadline, only bounded by the client connection
+	r.POST("/api/v1/single/upload", uploadlimit.Middleware, glacierManager.Upload)
+	r.POST("/api/v1/single/download", timeout, glacierManager.Download)
+	r.GET("/api/v1/single/download/status", timeout, glacierManager.DownloadStatus)
+	r.GET("/api/v1/restore-estimate", timeout, glacierManager.RestoreEstimate)
+	r.GET("/api/v1/get-archive", timeout, GetArchive)
+	r.POST("/api/v1/single/present", timeout, PresentFilesHandler)
+	r.GET("/api/v1/duplicates", timeout, DuplicatesResponse)
+	r.GET("/api/v1/notifications", timeout, NotificationsResponse)
+	r.PUT("/api/v1/notifications", timeout, MarkNotificationsHandler)
+
+	admin := r.Group("/api/v1/admin", timeout, requireStaff)
+	admin.GET("/organizations/:orgId/restore-budget", glacierManager.GetRestoreBudget)
+	admin.PUT("/organizations/:orgId/restore-budget", glacierManager.SetRestoreBudget)
+	admin.POST("/organizations/:orgId/invitations", CreateInvitationHandler)
+	admin.DELETE("/organizations/:orgId/invitations/:token", RevokeInvitationHandler)
+	admin.GET("/organizations/:orgId/features", FeatureFlagsResponse)
+	admin.PUT("/organizations/:orgId/features/:flag", SetFeatureFlagHandler)
+	admin.GET("/organizations/:orgId/api-quota", ApiQuotaResponse)
+	admin.PUT("/organizations/:orgId/api-quota", SetApiQuotaHandler)
+	admin.GET("/billing/usage", glacierManager.BillingUsage)
+	admin.GET("/slowlog", slowlog.SlowlogResponse)
+
+	if config.DebugConfig.EnablePprof {
+		registerDebugRoutes(r)
+	}
 
-	r.GET("/api/v1/ping", PingResponse)
-	r.POST("/api/v1/auth-token", GetAuthenticationTokenHandler)
-	r.GET("/api/v1/auth/ping", AuthPing)
-	r.POST("/api/v1/registrations", RegistrationsHandler)
-	r.GET("/api/v1/account/info", AccountInfoResponse)
-	r.POST("/api/v1/single/upload", glacierManager.Upload)
-	r.POST("/api/v1/single/download", glacierManager.Download)
-	r.GET("/api/v1/get-archive", GetArchive)
+	glacierManager.StartStagingCleanup()
+	if err := glacierManager.ResumeRestoreJobs(context.Background()); err != nil {
+		log.Printf("could not resume restores: %v", err)
+	}
 
-	if err := r.Run(config.ServerConfig.Port); nil != err {
-		panic(err)
+	server := &http.Server{
+		Addr:              config.ServerConfig.Port,
+		Handler:           r,
+		ReadHeaderTimeout: time.Duration(config.ServerConfig.ReadTimeoutSecs) * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	// on SIGTERM stop accepting connections and let in-flight uploads and downloads finish
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	shutdownTimeout := time.Duration(config.ServerConfig.ShutdownTimeoutSecs) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	log.Printf("received %s, draining requests for up to %s", sig, shutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	// restores still waiting for Glacier are resumed by the next instance that starts
+	if err := glacierManager.ReleaseRestoreJobs(context.Background()); err != nil {
+		log.Printf("could not release restores: %v", err)
+	}
+	if err != nil {
+		log.Printf("shutdown: %v", err)
+		return
+	}
+	log.Print("server stopped")
+}
+
+// Time given to in-flight requests to finish when the server is stopped
+const defaultShutdownTimeout = 30 * time.Second
+
+// Give the request a deadline that context aware database and storage calls honour,
+// routeSecs overrides d for the routes it lists, keyed by route path
+func withTimeout(d time.Duration, routeSecs map[string]int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := d
+		if secs, ok := routeSecs[c.FullPath()]; ok {
+			d = time.Duration(secs) * time.Second
+		}
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
 	}
 }
 
@@ -51,20 +177,89 @@ func enableCors(c *gin.Context) {
 	c.Request.Header.Add("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
 }
 
+// Serve the runtime profiles and expvar variables to staff users
+func registerDebugRoutes(r *gin.Engine) {
+	debug := r.Group("/debug", requireStaff)
+	debug.GET("/vars", gin.WrapH(expvar.Handler()))
+	debug.GET("/pprof/", gin.WrapF(pprof.Index))
+	debug.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+	for _, profile := range []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"} {
+		debug.GET("/pprof/"+profile, gin.WrapH(pprof.Handler(profile)))
+	}
+}
+
+// Only let staff users through to the admin endpoints
+func requireStaff(c *gin.Context) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		c.Abort()
+		return
+	}
+
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		c.Abort()
+		return
+	}
+	if !userDetails["is_staff"].(bool) {
+		c.String(http.StatusForbidden, errors.New("staff permission required").Error())
+		c.Abort()
+		return
+	}
+	c.Set("user_id", userDetails["user_id"])
+	c.Set("org_id", userDetails["org_id"])
+	c.Next()
+}
+
 func PingResponse(c *gin.Context) {
 	c.String(http.StatusOK, "pong")
 }
 
+// Set at release time with -ldflags "-X main.buildVersion=..."
+var buildVersion = "dev"
+
+// Tell clients which features this server has, so they can adapt instead of probing endpoints
+func ServerFeaturesResponse(c *gin.Context) {
+	config := configread.Configuration
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{
+		"version": buildVersion,
+		"go":      runtime.Version(),
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+		"features": gin.H{
+			"cold_storage":     true,
+			"restore_estimate": len(config.AWSConfig.RestorePricing) > 0,
+			"invite_only":      config.CoolAppConf.InviteOnly,
+			"api_quotas":       apiQuotaDefaults() != util.ApiQuota{},
+			"access_log":       config.AccessLogConfig.Enable,
+			"pprof":            config.DebugConfig.EnablePprof,
+			"notifications":    true,
+			"onlyoffice":       false,
+			"webdav":           false,
+			"search":           false,
+			"thumbnails":       false,
+		},
+	}})
+}
+
 func GetArchive(c *gin.Context) {
 	err1 := c.Request.ParseForm()
 	if err1 != nil {
 		c.String(http.StatusBadRequest, err1.Error())
 	} else {
 		archiveId := c.Request.FormValue("archiveId")
-		res, err := dba.GetArchive(archiveId)
+		res, err := dba.GetArchive(c.Request.Context(), archiveId)
 		if err != nil {
-			c.String(http.StatusBadRequest, err1.Error())
+			c.String(http.StatusBadRequest, err.Error())
+			return
 		}
+		glacierManager.SetChecksumHeader(c, res)
 		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": res})
 	}
 }
@@ -81,7 +276,7 @@ func GetAuthenticationTokenHandler(c *gin.Context) {
 		if username == "" || password == "" {
 			c.String(http.StatusNotAcceptable, "please enter a not void username and password")
 		} else {
-			tokenDetails, err := authenticate.GetToken(username, password)
+			tokenDetails, err := authenticate.GetToken(c.Request.Context(), username, password)
 			if err != nil {
 				c.String(http.StatusInternalServerError, err.Error())
 			} else {
@@ -99,11 +294,25 @@ func RegistrationsHandler(c *gin.Context) {
 	} else {
 		username := c.Request.FormValue("username")
 		password := c.Request.FormValue("password")
+		invite := c.Request.FormValue("invite")
 		if username == "" || password == "" {
 			c.String(http.StatusOK, "Please enter not void username and password.")
+		} else if invite == "" && configread.Configuration.CoolAppConf.InviteOnly {
+			c.String(http.StatusForbidden, "registration requires an invitation")
 		} else {
-			response, err := register.RegisterUser(username, password)
-			if err != nil {
+			var response string
+			var err error
+			if invite != "" {
+				response, err = register.RegisterInvitedUser(c.Request.Context(), username, password, invite)
+			} else {
+				response, err = register.RegisterUser(c.Request.Context(), username, password)
+			}
+			var policyErr *register.PasswordPolicyError
+			if err == register.ErrInvitationNotValid || err == register.ErrUserLimitReached {
+				c.String(http.StatusForbidden, err.Error())
+			} else if errors.As(err, &policyErr) {
+				c.String(http.StatusBadRequest, err.Error())
+			} else if err != nil {
 				c.String(http.StatusOK, err.Error())
 			} else {
 				c.String(http.StatusOK, response)
@@ -112,6 +321,222 @@ func RegistrationsHandler(c *gin.Context) {
 	}
 }
 
+// Invite links are valid for a week unless app.inviteExpiryDays says otherwise
+const defaultInviteExpiryDays = 7
+
+// Generate an invite link that registers users into an organization
+func CreateInvitationHandler(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	inv := util.Invitation{
+		Org_id:       orgId,
+		Email_domain: strings.TrimPrefix(strings.ToLower(c.PostForm("email_domain")), "@"),
+		Quota_total:  10,
+		Created_by:   c.GetInt("user_id"),
+		Max_uses:     1,
+	}
+	if quota := c.PostForm("quota_total"); quota != "" {
+		inv.Quota_total, err = strconv.Atoi(quota)
+		if err != nil || inv.Quota_total < 0 {
+			c.String(http.StatusBadRequest, "quota not valid")
+			return
+		}
+	}
+	if uses := c.PostForm("max_uses"); uses != "" {
+		inv.Max_uses, err = strconv.Atoi(uses)
+		if err != nil || inv.Max_uses < 1 {
+			c.String(http.StatusBadRequest, "max uses not valid")
+			return
+		}
+	}
+
+	expiryDays := configread.Configuration.CoolAppConf.InviteExpiryDays
+	if expiryDays <= 0 {
+		expiryDays = defaultInviteExpiryDays
+	}
+	inv.Expires_at = time.Now().AddDate(0, 0, expiryDays).Format("2006-01-02 15:04:05")
+
+	token, err := dba.BuildRandomToken()
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	inv.Invite_token = token["auth_token"]
+
+	if err := dba.InsertInvitation(c.Request.Context(), inv); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": gin.H{
+		"invite_token": inv.Invite_token,
+		"invite_url":   configread.Configuration.CoolAppConf.PrefixUrl + "/api/v1/registrations?invite=" + inv.Invite_token,
+		"org_id":       inv.Org_id,
+		"email_domain": inv.Email_domain,
+		"expires_at":   inv.Expires_at,
+		"max_uses":     inv.Max_uses,
+	}})
+}
+
+// Revoke an invitation, its link stops working at once
+func RevokeInvitationHandler(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	deleted, err := dba.DeleteInvitation(c.Request.Context(), orgId, c.Param("token"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		c.String(http.StatusNotFound, "invitation not found")
+		return
+	}
+	c.String(http.StatusOK, "invitation revoked")
+}
+
+// Features of an organization and whether they are on for it
+func FeatureFlagsResponse(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	features := gin.H{}
+	for _, name := range featureflag.Names() {
+		features[name] = featureflag.Enabled(c.Request.Context(), name, orgId)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{"org_id": orgId, "features": features}})
+}
+
+// Turn a feature on or off for an organization, an empty enabled value goes back to the configured rollout
+func SetFeatureFlagHandler(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+	name := c.Param("flag")
+	known := false
+	for _, flag := range featureflag.Names() {
+		known = known || flag == name
+	}
+	if !known {
+		c.String(http.StatusNotFound, "feature not found")
+		return
+	}
+
+	enabled := sql.NullBool{}
+	if value := c.PostForm("enabled"); value != "" {
+		enabled.Bool, err = strconv.ParseBool(value)
+		if err != nil {
+			c.String(http.StatusBadRequest, "enabled not valid")
+			return
+		}
+		enabled.Valid = true
+	}
+
+	if err := dba.SetFeatureFlagOverride(c.Request.Context(), name, orgId, enabled); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	FeatureFlagsResponse(c)
+}
+
+// Configured API quota of the organizations without their own
+func apiQuotaDefaults() util.ApiQuota {
+	config := configread.Configuration
+	return util.ApiQuota{
+		Daily_calls:   config.ServerConfig.DailyCallQuota,
+		Daily_bytes:   config.ServerConfig.DailyBytesQuota,
+		Monthly_calls: config.ServerConfig.MonthlyCallQuota,
+		Monthly_bytes: config.ServerConfig.MonthlyBytesQuota,
+	}
+}
+
+// Organization whose API quota the calls of a token count against
+func quotaOrganization(ctx context.Context, token string) (int, bool) {
+	userDetails, err := authenticate.ValidateToken(ctx, token)
+	if err != nil {
+		return 0, false
+	}
+	return userDetails["org_id"].(int), true
+}
+
+// API quota of an organization and what it used of it today and this month
+func ApiQuotaResponse(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	quota, found, err := dba.GetApiQuota(c.Request.Context(), orgId)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		quota = apiQuotaDefaults()
+	}
+	now := time.Now().UTC()
+	used, err := dba.GetApiUsage(c.Request.Context(), orgId, []string{now.Format("2006-01-02"), now.Format("2006-01")})
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{"org_id": orgId, "custom": found, "quota": quota, "usage": used}})
+}
+
+// Give an organization its own API quota. Missing values take the configured default,
+// and a request without any value goes back to the configured quota.
+func SetApiQuotaHandler(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	quota := apiQuotaDefaults()
+	custom := false
+	for name, limit := range map[string]*int64{
+		"daily_calls":   &quota.Daily_calls,
+		"daily_bytes":   &quota.Daily_bytes,
+		"monthly_calls": &quota.Monthly_calls,
+		"monthly_bytes": &quota.Monthly_bytes,
+	} {
+		value := c.PostForm(name)
+		if value == "" {
+			continue
+		}
+		*limit, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || *limit < 0 {
+			c.String(http.StatusBadRequest, "%s not valid", name)
+			return
+		}
+		custom = true
+	}
+
+	if custom {
+		err = dba.SetApiQuota(c.Request.Context(), orgId, quota)
+	} else {
+		err = dba.DeleteApiQuota(c.Request.Context(), orgId)
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	apiquota.Invalidate()
+	ApiQuotaResponse(c)
+}
+
 func AuthPing(c *gin.Context) {
 
 	// authToken := strings.Split(c.Request.Header.Get("Authorization"), "Token ")[1]
@@ -123,7 +548,7 @@ func AuthPing(c *gin.Context) {
 		authToken := data[1]
 
 		// userDetails, err := authenticate.ValidateToken(authToken)
-		_, err := authenticate.ValidateToken(authToken)
+		_, err := authenticate.ValidateToken(c.Request.Context(), authToken)
 
 		if err != nil {
 			c.String(http.StatusOK, err.Error())
@@ -144,12 +569,13 @@ func AccountInfoResponse(c *gin.Context) {
 		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
 	} else {
 		authToken := data[1]
-		userDetails, err := authenticate.ValidateToken(authToken)
+		userDetails, err := authenticate.ValidateToken(c.Request.Context(), authToken)
 
 		if err != nil {
 			c.String(403, errors.New("invalid token").Error())
 		} else {
 			username := fmt.Sprint(userDetails["username"])
+			user_id := userDetails["user_id"].(int)
 			ss := strings.Split(username, "@")
 			name := ss[0]
 			c.JSON(http.StatusOK, gin.H{
@@ -177,7 +603,137 @@ func AccountInfoResponse(c *gin.Context) {
 				"total": 0,
 
 				"email": username,
+
+				"upload_speed": int64(transferstats.UploadSpeed(user_id)),
+
+				"suggested_chunk_size": transferstats.SuggestedChunkSize(user_id),
 			})
 		}
 	}
 }
+
+// Most hashes a client can check in one request
+const maxPresentHashes = 1000
+
+// Tell a client which of its files are already stored, by the SHA-256 of their content,
+// so it doesn't upload them again
+func PresentFilesHandler(c *gin.Context) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		return
+	}
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		return
+	}
+
+	hashes := c.PostFormArray("hash")
+	if len(hashes) == 0 || len(hashes) > maxPresentHashes {
+		c.String(http.StatusBadRequest, "please send between 1 and %d hashes", maxPresentHashes)
+		return
+	}
+	for i, hash := range hashes {
+		hashes[i], err = util.NormalizeSHA256(hash)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	present, err := dba.FindArchivesByHash(c.Request.Context(), userDetails["user_id"].(int), hashes)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	missing := []string{}
+	for _, hash := range hashes {
+		if _, ok := present[hash]; !ok {
+			missing = append(missing, hash)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{"present": present, "missing": missing}})
+}
+
+// Report the files a user uploaded more than once and the space the extra copies take
+func DuplicatesResponse(c *gin.Context) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		return
+	}
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		return
+	}
+
+	archives, err := dba.GetDuplicateArchives(c.Request.Context(), userDetails["user_id"].(int))
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	duplicates := util.GroupDuplicates(archives)
+	wasted := 0.0
+	for _, d := range duplicates {
+		wasted += d.Wasted_space
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": duplicates, "wasted_space": wasted})
+}
+
+func NotificationsResponse(c *gin.Context) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		return
+	}
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		return
+	}
+	user_id := userDetails["user_id"].(int)
+
+	notifications, err := dba.GetNotifications(c.Request.Context(), user_id, 50)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	unread, err := dba.CountUnreadNotifications(c.Request.Context(), user_id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": notifications, "unread_count": unread})
+}
+
+func MarkNotificationsHandler(c *gin.Context) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		return
+	}
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		return
+	}
+
+	// without an id every notification of the user is marked as read
+	notificationId := 0
+	if id := c.PostForm("id"); id != "" {
+		notificationId, err = strconv.Atoi(id)
+		if err != nil {
+			c.String(http.StatusBadRequest, "notification id not valid")
+			return
+		}
+	}
+
+	err = dba.MarkNotificationsSeen(c.Request.Context(), userDetails["user_id"].(int), notificationId)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
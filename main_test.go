@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"cool-storage-api/authenticate"
 	"cool-storage-api/register"
 	"encoding/json"
@@ -40,6 +41,33 @@ func TestPingResponse(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestServerFeaturesResponse(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	r := SetUpRouter()
+	gin.SetMode(gin.TestMode)
+
+	r.GET("/api/v1/server-features", ServerFeaturesResponse)
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/server-features", nil)
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		Data struct {
+			Version  string          `json:"version"`
+			Features map[string]bool `json:"features"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, buildVersion, response.Data.Version)
+	assert.True(t, response.Data.Features["cold_storage"])
+	assert.False(t, response.Data.Features["webdav"])
+}
+
 func TestGetAuthenticationTokenHandler(t *testing.T) {
 
 	w := httptest.NewRecorder()
@@ -49,7 +77,7 @@ func TestGetAuthenticationTokenHandler(t *testing.T) {
 	r.POST("/api/v1/auth-token/", GetAuthenticationTokenHandler)
 
 	randomUser, randomPassword := getNewFakeUserPassword()
-	register.RegisterUser(randomUser, randomPassword)
+	register.RegisterUser(context.Background(), randomUser, randomPassword)
 
 	v := make(url.Values)
 	v.Set("username", randomUser)
@@ -78,7 +106,7 @@ func TestGetAuthenticationTokenHandler(t *testing.T) {
 
 	token := got["token"].(string)
 
-	_, err2 := authenticate.ValidateToken(token)
+	_, err2 := authenticate.ValidateToken(context.Background(), token)
 	if err2 != nil {
 		t.Errorf("Expected %v but got %v", nil, err2)
 	}
@@ -128,9 +156,9 @@ func TestAuthPing(t *testing.T) {
 	}
 
 	randomUser, randomPassword := getNewFakeUserPassword()
-	register.RegisterUser(randomUser, randomPassword)
+	register.RegisterUser(context.Background(), randomUser, randomPassword)
 
-	tokenDetails, _ := authenticate.GetToken(randomUser, randomPassword)
+	tokenDetails, _ := authenticate.GetToken(context.Background(), randomUser, randomPassword)
 	auth_token := tokenDetails["auth_token"]
 	value := "Token " + auth_token
 	req.Header.Set("Authorization", value)
@@ -159,9 +187,9 @@ func TestAccountInfoResponse(t *testing.T) {
 	}
 
 	randomUser, randomPassword := getNewFakeUserPassword()
-	register.RegisterUser(randomUser, randomPassword)
+	register.RegisterUser(context.Background(), randomUser, randomPassword)
 
-	tokenDetails, _ := authenticate.GetToken(randomUser, randomPassword)
+	tokenDetails, _ := authenticate.GetToken(context.Background(), randomUser, randomPassword)
 	auth_token := tokenDetails["auth_token"]
 	value := "Token " + auth_token
 	req.Header.Set("Authorization", value)
@@ -193,6 +221,7 @@ func SetUpRouter() *gin.Engine {
 func getNewFakeUserPassword() (string, string) {
 	rand.Seed(time.Now().UnixNano())
 	randomUser := strconv.Itoa(rand.Intn(1000000))
-	randomPassword := strconv.Itoa(rand.Intn(1000000))
+	// long and mixed enough for the password policy of the dist configuration
+	randomPassword := "Test-Password-" + strconv.Itoa(rand.Intn(1000000))
 	return randomUser, randomPassword
 }
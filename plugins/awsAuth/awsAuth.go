@@ -3,8 +3,11 @@ package awsAuth
 import (
 	"context"
 	"cool-storage-api/configread"
+	"cool-storage-api/plugins/awsFaults"
 	"errors"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -13,15 +16,37 @@ import (
 
 var awsConfig = configread.Configuration.AWSConfig
 
+var faultsEnabled = configureFaults(configread.Configuration.DebugConfig.AWSFaults)
+
+func configureFaults(conf map[string]configread.AWSFaultConf) bool {
+	if len(conf) == 0 {
+		return false
+	}
+	faults := map[string]awsFaults.Fault{}
+	for operation, f := range conf {
+		faults[operation] = awsFaults.Fault{Latency: time.Duration(f.LatencyMillis) * time.Millisecond, ErrorRate: f.ErrorRate}
+	}
+	awsFaults.Configure(faults)
+	log.Printf("WARNING: injecting faults into AWS calls %v", conf)
+	return true
+}
+
 func Authenticate() (aws.Config, error) {
 	isProfileAuth := strings.Contains(awsConfig.AuthMethod, "profile")
 	isKeyAuth := strings.Contains(awsConfig.AuthMethod, "key") || strings.Contains(awsConfig.AuthMethod, "secret")
+	var cfg aws.Config
+	var err error
 	if isProfileAuth {
-		return AuthWithProfile("")
+		cfg, err = AuthWithProfile("")
 	} else if isKeyAuth {
-		return AuthWithCredentials()
+		cfg, err = AuthWithCredentials()
+	} else {
+		return aws.Config{}, errors.New("No autentification method found")
+	}
+	if err == nil && faultsEnabled {
+		cfg.APIOptions = append(cfg.APIOptions, awsFaults.Register)
 	}
-	return aws.Config{}, errors.New("No autentification method found")
+	return cfg, err
 }
 
 func AuthWithProfile(profileName string) (aws.Config, error) {
@@ -0,0 +1,74 @@
+package awsFaults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Returned by AWS calls failed on purpose
+var ErrInjected = errors.New("injected fault")
+
+// What to do to the calls of an operation
+type Fault struct {
+	// added before the call is made
+	Latency time.Duration
+	// share of the calls, from 0 to 1, failed with ErrInjected after the latency
+	ErrorRate float64
+}
+
+var (
+	mu     sync.RWMutex
+	faults = map[string]Fault{}
+)
+
+// Set the faults by operation name, e.g. "UploadArchive", "*" applies to the operations without their own
+func Configure(f map[string]Fault) {
+	mu.Lock()
+	defer mu.Unlock()
+	faults = f
+	if faults == nil {
+		faults = map[string]Fault{}
+	}
+}
+
+func lookup(operation string) (Fault, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if f, ok := faults[operation]; ok {
+		return f, true
+	}
+	f, ok := faults["*"]
+	return f, ok
+}
+
+// Add fault injection to the middleware stack of an AWS client, to be used in aws.Config.APIOptions
+func Register(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("FaultInjection", inject), middleware.After)
+}
+
+func inject(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	operation := awsmiddleware.GetOperationName(ctx)
+	f, ok := lookup(operation)
+	if !ok {
+		return next.HandleInitialize(ctx, in)
+	}
+
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-ctx.Done():
+			return middleware.InitializeOutput{}, middleware.Metadata{}, ctx.Err()
+		}
+	}
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+		return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf("%s: %w", operation, ErrInjected)
+	}
+	return next.HandleInitialize(ctx, in)
+}
@@ -0,0 +1,56 @@
+package awsFaults_test
+
+import (
+	"context"
+	"cool-storage-api/plugins/awsFaults"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Answers every request with an error instead of reaching AWS, counting the requests
+type offlineClient struct {
+	requests int
+}
+
+func (c *offlineClient) Do(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return nil, errors.New("offline")
+}
+
+func TestRegister(t *testing.T) {
+	awsFaults.Configure(map[string]awsFaults.Fault{
+		"DescribeJob": {Latency: 50 * time.Millisecond, ErrorRate: 1},
+	})
+	defer awsFaults.Configure(nil)
+
+	httpClient := &offlineClient{}
+	client := glacier.New(glacier.Options{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		HTTPClient:       httpClient,
+		RetryMaxAttempts: 1,
+		APIOptions:       []func(*middleware.Stack) error{awsFaults.Register},
+	})
+
+	start := time.Now()
+	_, err := client.DescribeJob(context.Background(), &glacier.DescribeJobInput{AccountId: aws.String("-"), JobId: aws.String("job"), VaultName: aws.String("vault")})
+	if !errors.Is(err, awsFaults.ErrInjected) || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected %v after %v but got %v after %v", awsFaults.ErrInjected, 50*time.Millisecond, err, time.Since(start))
+	}
+	if httpClient.requests != 0 {
+		t.Errorf("Expected %v but got %v", 0, httpClient.requests)
+	}
+
+	// operations without a fault reach the HTTP client
+	_, err = client.ListJobs(context.Background(), &glacier.ListJobsInput{AccountId: aws.String("-"), VaultName: aws.String("vault")})
+	if errors.Is(err, awsFaults.ErrInjected) || httpClient.requests != 1 {
+		t.Errorf("Expected %v,%v but got %v,%v", nil, 1, err, httpClient.requests)
+	}
+}
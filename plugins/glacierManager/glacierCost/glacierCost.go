@@ -0,0 +1,50 @@
+package glacierCost
+
+import (
+	"cool-storage-api/configread"
+	"cool-storage-api/util"
+	"fmt"
+	"time"
+)
+
+// Retrieval tier used by the download endpoint
+const DefaultTier = "Standard"
+
+type Estimate struct {
+	ArchiveId string  `json:"archive_id"`
+	Size      float64 `json:"size"`
+	Tier      string  `json:"tier"`
+	Cost      float64 `json:"cost"`
+	ReadyAt   string  `json:"ready_at"`
+	// true when the cost is above the threshold and the restore has to be confirmed
+	NeedsConfirm bool `json:"needs_confirm"`
+}
+
+// Estimate the cost and ready time of restoring an archive with a retrieval tier
+func EstimateRestore(a util.Archive, tier string) (Estimate, error) {
+	awsConfig := configread.Configuration.AWSConfig
+
+	if tier == "" {
+		tier = DefaultTier
+	}
+	price, ok := awsConfig.RestorePricing[tier]
+	if !ok {
+		return Estimate{}, fmt.Errorf("no pricing configured for tier %s", tier)
+	}
+
+	size, err := util.ParseHumanFileSize(a.File_size)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	cost := size/(1<<30)*price.PerGB + price.PerRequest
+	readyAt := time.Now().Add(time.Duration(price.ReadyHours) * time.Hour)
+	return Estimate{
+		ArchiveId:    a.Vault_file_id,
+		Size:         size,
+		Tier:         tier,
+		Cost:         util.Round(cost, .5, 4),
+		ReadyAt:      readyAt.Format("2006-01-02 15:04:05"),
+		NeedsConfirm: awsConfig.RestoreConfirmThreshold > 0 && cost > awsConfig.RestoreConfirmThreshold,
+	}, nil
+}
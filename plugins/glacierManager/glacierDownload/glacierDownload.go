@@ -1,9 +1,12 @@
 package glacierDownload
 
 import (
+	"context"
 	"cool-storage-api/plugins/glacierManager/glacierJob"
 	"cool-storage-api/util"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,24 +17,45 @@ func Download(a util.Archive) error {
 	//2-wait for job is completed(ask for job description)
 	//3-get job output and write the file
 
-	jobId, err := glacierJob.Glacier_InitiateRetrievalJob(a.Vault_file_id, a.File_name)
+	jobId, err := glacierJob.Glacier_InitiateRetrievalJob(context.Background(), a.Vault_file_id, a.File_name)
 	if err != nil {
 		return err
-	} else {
-		i := 0
-		LIMIT := 300 //  wait at most 75h
-		for i < LIMIT {
-			i++
-			time.Sleep(15 * time.Minute)
-			completed, err := glacierJob.GlacierIsJobCompleted(jobId)
-			if err != nil {
-				return err
-			}
-			if completed {
-				_, err := glacierJob.Glacier_GetJobOutput(jobId, a.File_name)
+	}
+	return WaitAndDownload(jobId, a)
+}
+
+// Wait for a retrieval job to complete and write its output to the download folder
+func WaitAndDownload(jobId string, a util.Archive) error {
+	i := 0
+	LIMIT := 300 //  wait at most 75h
+	for i < LIMIT {
+		i++
+		time.Sleep(15 * time.Minute)
+		completed, err := glacierJob.GlacierIsJobCompleted(jobId)
+		if err != nil {
+			return err
+		}
+		if completed {
+			if _, err := glacierJob.Glacier_GetJobOutput(jobId, a.File_name); err != nil {
 				return err
 			}
+			return verifyDownload(a)
 		}
 	}
 	return errors.New("initiate retrieval archive job time limit")
 }
+
+// Compare a downloaded archive with the SHA-256 stored when it was uploaded
+func verifyDownload(a util.Archive) error {
+	if a.File_sha256 == "" {
+		return nil
+	}
+	fileHash, err := util.HashingReadFile(glacierJob.DownloadPath(a.File_name))
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(fileHash, a.File_sha256) {
+		return fmt.Errorf("checksum mismatch for archive %s: expected %s but got %s", a.Vault_file_id, a.File_sha256, fileHash)
+	}
+	return nil
+}
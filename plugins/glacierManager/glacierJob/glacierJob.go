@@ -4,11 +4,13 @@ import (
 	"context"
 	"cool-storage-api/configread"
 	"cool-storage-api/plugins/awsAuth"
+	"cool-storage-api/slowlog"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/glacier"
@@ -61,7 +63,7 @@ func Glacier_InitiateInventoryJob() {
 	fmt.Println(*result)
 }
 
-func Glacier_InitiateRetrievalJob(archiveId string, archiveName string) (string, error) {
+func Glacier_InitiateRetrievalJob(ctx context.Context, archiveId string, archiveName string) (string, error) {
 	cfg, err := awsAuth.Authenticate()
 	if err != nil {
 		// log.Fatalf("failed to load AWS configuration, %v", err)
@@ -84,7 +86,9 @@ func Glacier_InitiateRetrievalJob(archiveId string, archiveName string) (string,
 		VaultName: aws.String(awsConfig.VaultName),
 	}
 
-	result, err := svc.InitiateJob(context.TODO(), input)
+	start := time.Now()
+	result, err := svc.InitiateJob(ctx, input)
+	slowlog.Record(slowlog.Entry{Kind: "storage", Name: "glacier.InitiateJob", Detail: archiveId}, start)
 	if err != nil {
 		var nsk *types.NoSuchKey
 		if errors.As(err, &nsk) {
@@ -112,10 +116,10 @@ func Glacier_InitiateRetrievalJob(archiveId string, archiveName string) (string,
 // To get information about a previously initiated job
 // The example returns information about the previously initiated job specified by the
 // job ID.
-func Glacier_DescribeJob(jobId string) (glacier.DescribeJobOutput, error) {
+func Glacier_DescribeJob(ctx context.Context, jobId string) (glacier.DescribeJobOutput, error) {
 	cfg, err := awsAuth.Authenticate()
 	if err != nil {
-		log.Fatalf("failed to load AWS configuration, %v", err)
+		// log.Fatalf("failed to load AWS configuration, %v", err)
 		return glacier.DescribeJobOutput{}, errors.New("failed to load AWS configuration")
 	}
 
@@ -128,7 +132,7 @@ func Glacier_DescribeJob(jobId string) (glacier.DescribeJobOutput, error) {
 		VaultName: aws.String(awsConfig.VaultName),
 	}
 
-	result, err := svc.DescribeJob(context.TODO(), input)
+	result, err := svc.DescribeJob(ctx, input)
 	if err != nil {
 		var nsk *types.NoSuchKey
 		if errors.As(err, &nsk) {
@@ -161,7 +165,7 @@ func Glacier_DescribeJob(jobId string) (glacier.DescribeJobOutput, error) {
 }
 
 func GlacierIsJobCompleted(jobId string) (bool, error) {
-	jobInformation, err := Glacier_DescribeJob(jobId)
+	jobInformation, err := Glacier_DescribeJob(context.Background(), jobId)
 	if err != nil {
 		return false, err
 	}
@@ -217,8 +221,7 @@ func Glacier_GetJobOutput(jobId, fileName string) (int32, error) {
 		os.MkdirAll(pathsample, 0700) // Create your file
 	}
 
-	outputFile := "../cool-storage-api/download/"
-	outputFilename := outputFile + fileName
+	outputFilename := DownloadPath(fileName)
 
 	out, err := os.Create(outputFilename)
 	if err != nil {
@@ -230,7 +233,9 @@ func Glacier_GetJobOutput(jobId, fileName string) (int32, error) {
 	bufferLength := 1024 * 1024 * 100 //100MB
 
 	buf := make([]byte, bufferLength) //make([]byte, 1024*1024*100) // 100MB
+	start := time.Now()
 	_, err = io.CopyBuffer(out, result.Body, buf)
+	slowlog.Record(slowlog.Entry{Kind: "storage", Name: "glacier.GetJobOutput", Detail: jobId}, start)
 	if err != nil {
 		// handle error //PENDING
 		return 400, err
@@ -241,6 +246,11 @@ func Glacier_GetJobOutput(jobId, fileName string) (int32, error) {
 	return result.Status, nil
 }
 
+// Path where the output of a retrieval job is saved
+func DownloadPath(fileName string) string {
+	return "../cool-storage-api/download/" + fileName
+}
+
 // To list jobs for a vault
 // The example lists jobs for the vault input.
 func Glacier_ListJobs() (glacier.ListJobsOutput, error) {
@@ -5,18 +5,32 @@ import (
 	"cool-storage-api/authenticate"
 	"cool-storage-api/configread"
 	"cool-storage-api/dba"
+	"cool-storage-api/plugins/glacierManager/glacierCost"
 	"cool-storage-api/plugins/glacierManager/glacierDownload"
+	"cool-storage-api/plugins/glacierManager/glacierJob"
 	"cool-storage-api/plugins/glacierManager/glacierUpload"
+	"cool-storage-api/transferstats"
 	"log"
 	"time"
 
 	"cool-storage-api/util"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	glaciertypes "github.com/aws/aws-sdk-go-v2/service/glacier/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 	"github.com/gin-gonic/gin"
@@ -24,36 +38,130 @@ import (
 
 var awsConfig = configread.Configuration.AWSConfig
 
+// content refused on upload, read once at startup
+var blockedHashes = loadBlockedHashes(configread.Configuration.CoolAppConf.BlockedHashesFile)
+
+func loadBlockedHashes(path string) map[string]bool {
+	if path == "" {
+		return map[string]bool{}
+	}
+	hashes, err := util.LoadHashList(path)
+	if err != nil {
+		panic(err)
+	}
+	return hashes
+}
+
+const (
+	stagingDir            = "./upload/"
+	stagingSuffix         = ".part"
+	defaultStagingTimeout = 60 * time.Minute
+	// restore spending is tracked per calendar month
+	spendingPeriod = "2006-01"
+)
+
 func Upload(c *gin.Context) {
 	// Get data from request
 	userToken := c.GetHeader("user-token")
-	tokenDetails, err := authenticate.ValidateToken(userToken)
+	tokenDetails, err := authenticate.ValidateToken(c.Request.Context(), userToken)
 	if err != nil {
 		c.String(http.StatusBadRequest, "user token not valid")
 		return
 	}
-	_, uploadFile, err := c.Request.FormFile("file")
+	// normalized before anything is looked up or stored under the name
+	filename, err := util.NormalizeFileName(c.GetHeader("uploader-file-name"), configread.Configuration.CoolAppConf.UnicodePolicy)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	// without chunk headers the whole file is sent in one request
+	chunkid, err := strconv.Atoi(headerOr(c, "uploader-chunk-number", "1"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "chunk number not valid")
+		return
+	}
+	chunksTotal, err := strconv.Atoi(headerOr(c, "uploader-chunks-total", "1"))
+	if err != nil || chunksTotal < 1 || chunkid < 1 || chunkid > chunksTotal {
+		c.String(http.StatusBadRequest, "chunk %d of %s not valid", chunkid, c.GetHeader("uploader-chunks-total"))
+		return
+	}
+	fileId := c.GetHeader("uploader-file-id")
+	if fileId == "" {
+		fileId = filename
+	}
+	user_id := tokenDetails["user_id"].(int)
+	c.Set("user_id", user_id)
+	c.Set("org_id", tokenDetails["org_id"])
+	dst := stagingPath(user_id, fileId) //<- destino del archivo
+	progressPath := chunkProgressPath(dst)
 
-	file, err := uploadFile.Open()
-	fileData, err := ioutil.ReadAll(file)
+	part, err := filePart(c.Request)
 	if err != nil {
 		c.String(http.StatusBadRequest, "get form err: %s", err.Error())
 		return
 	}
 
-	filename := c.GetHeader("uploader-file-name")
-	chunkid := c.GetHeader("uploader-chunk-number")
-	chunksTotal := c.GetHeader("uploader-chunks-total")
-	path := "./upload/"
-	dst := path + filename //<- destino del archivo
+	// the first chunk opens a new upload session, later ones must follow what is already staged
+	offset, err := util.ChunkOffset(dst, progressPath, chunkid)
+	if errors.Is(err, util.ErrChunkOutOfOrder) {
+		c.String(http.StatusConflict, "%s of file %s, please upload it again from the first chunk", err.Error(), filename)
+		return
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, "upload aborted: %s", err.Error())
+		return
+	}
 
-	// marge actual chunck with prev
-	util.AppendData(dst, fileData)
+	// marge actual chunck with prev, straight from the request body
+	received := time.Now()
+	written, err := util.AppendReader(dst, part)
+	if err != nil {
+		os.Remove(dst)
+		os.Remove(progressPath)
+		c.String(http.StatusInternalServerError, "upload aborted: %s", err.Error())
+		return
+	}
+	transferstats.RecordUpload(user_id, written, time.Since(received))
+	if err := util.SaveChunkProgress(progressPath, util.ChunkProgress{Chunk: chunkid, Offset: offset, Size: offset + written}); err != nil {
+		c.String(http.StatusInternalServerError, "upload aborted: %s", err.Error())
+		return
+	}
 
 	//AWS-Glacier
 	if chunkid == chunksTotal {
-		user_id := tokenDetails["user_id"]
-		db := glacierUpload.Upload(dst, filename, user_id.(int))
+		// the staged file is only needed until the archive is committed or rejected
+		defer os.Remove(dst)
+		defer os.Remove(progressPath)
+
+		if maxStored := configread.Configuration.LicenseConfig.MaxStoredBytes; maxStored > 0 {
+			stored, err := dba.GetStoredBytes(c.Request.Context())
+			if err != nil {
+				c.String(http.StatusInternalServerError, "upload aborted: %s", err.Error())
+				return
+			}
+			if stored+float64(offset+written) > float64(maxStored) {
+				c.String(http.StatusInsufficientStorage, "storing %s would go over the licensed storage of %s", filename, util.HumanFileSize(float64(maxStored)))
+				return
+			}
+		}
+
+		// the hash is kept with the archive so downloads can be verified end-to-end
+		fileHash, err := util.HashingReadFile(dst)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "upload aborted: %s", err.Error())
+			return
+		}
+		if hash := announcedHash(c.Request); hash != "" && !strings.EqualFold(hash, fileHash) {
+			c.String(http.StatusBadRequest, "file hash mismatch: the upload of %s was corrupted, please upload it again", filename)
+			return
+		}
+		if blockedHashes[fileHash] {
+			log.Printf("refused upload of blocked content %s by user %d as %q", fileHash, user_id, filename)
+			c.String(http.StatusForbidden, "the content of %s is not allowed", filename)
+			return
+		}
+
+		// no deadline here, but the upload is abandoned if the client goes away
+		db := glacierUpload.Upload(c.Request.Context(), dst, filename, fileHash, user_id)
 		if db != nil {
 			c.String(http.StatusInternalServerError, db.Error())
 		} else {
@@ -61,44 +169,509 @@ func Upload(c *gin.Context) {
 			c.String(http.StatusOK, "File %s uploaded successfully", filename)
 		}
 	} else {
-		c.String(http.StatusOK, "Chunk # %s of file %s uploaded successfully.", chunkid, filename)
+		c.String(http.StatusOK, "Chunk # %d of file %s uploaded successfully.", chunkid, filename)
+	}
+}
+
+func headerOr(c *gin.Context, key string, fallback string) string {
+	if value := c.GetHeader(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Get the "file" part of a multipart upload without buffering the parts before it
+func filePart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, http.ErrMissingFile
+		} else if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// SHA-256 the client announced for the whole file, as a header or as a trailer of a streamed body
+func announcedHash(r *http.Request) string {
+	if hash := r.Header.Get("uploader-file-hash"); hash != "" {
+		return hash
+	}
+	// trailers are only known once the body has been read to the end
+	io.Copy(io.Discard, r.Body)
+	return r.Trailer.Get("uploader-file-hash")
+}
+
+// Path where the chunks of an upload are staged until the last one arrives
+func stagingPath(user_id int, fileId string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", user_id, fileId)))
+	return fmt.Sprintf("%s%x%s", stagingDir, sum, stagingSuffix)
+}
+
+// Path of the progress kept next to a staged upload, with the staging suffix so the cleanup removes it too
+func chunkProgressPath(dst string) string {
+	return strings.TrimSuffix(dst, stagingSuffix) + ".chunks" + stagingSuffix
+}
+
+// Periodically remove staged uploads that were abandoned before their last chunk
+func StartStagingCleanup() {
+	timeout := time.Duration(configread.Configuration.CoolAppConf.UploadStagingTimeoutMins) * time.Minute
+	if timeout <= 0 {
+		timeout = defaultStagingTimeout
+	}
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := util.RemoveStaleFiles(stagingDir, stagingSuffix, timeout); err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+}
+
+// name of this instance in restore_jobs, so each job is waited for by a single instance
+var instanceId = restoreInstanceId(configread.Configuration.ServerConfig.InstanceId)
+
+func restoreInstanceId(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return fmt.Sprintf("pid-%d", os.Getpid())
+}
+
+// Go back to waiting for the restores this instance was waiting for when it last stopped,
+// and for those nobody waits for, so their files are still written and their owners notified.
+// A job is claimed by a single instance, the others leave it alone.
+func ResumeRestoreJobs(ctx context.Context) error {
+	jobs, err := dba.GetRestoreJobsByStatus(ctx, "in_progress")
+	if err != nil {
+		return err
+	}
+	resumed := 0
+	for _, job := range jobs {
+		if job.Owner != instanceId {
+			if job.Owner != "" {
+				continue
+			}
+			claimed, err := dba.ClaimRestoreJob(ctx, job.Job_id, instanceId)
+			if err != nil {
+				log.Printf("could not claim retrieval job %s: %v", job.Job_id, err)
+				continue
+			} else if !claimed {
+				continue
+			}
+		}
+		archive, err := dba.GetArchive(ctx, job.Archive_id)
+		if err != nil {
+			log.Printf("could not resume retrieval job %s of archive %s: %v", job.Job_id, job.Archive_id, err)
+			if err := dba.FinishRestoreJob(ctx, job.Job_id, "failed"); err != nil {
+				log.Printf("could not record the end of retrieval job %s: %v", job.Job_id, err)
+			}
+			continue
+		}
+		go waitForRestore(job, archive)
+		resumed++
+	}
+	if resumed > 0 {
+		log.Printf("resumed %d retrieval jobs", resumed)
+	}
+	return nil
+}
+
+// Hand the restores this instance is still waiting for over to the next instance that starts
+func ReleaseRestoreJobs(ctx context.Context) error {
+	released, err := dba.ReleaseRestoreJobs(ctx, instanceId)
+	if err != nil {
+		return err
+	}
+	if released > 0 {
+		log.Printf("released %d retrieval jobs", released)
 	}
+	return nil
 }
 
 func Download(c *gin.Context) {
+	userDetails, ok := requestUser(c)
+	if !ok {
+		return
+	}
 	err1 := c.Request.ParseForm()
 	if err1 != nil {
 		c.String(http.StatusBadRequest, err1.Error())
-	} else {
+		return
+	}
 
-		archiveId := c.Request.FormValue("archiveId")
-		archiveStruc, err := dba.GetArchive(archiveId)
-		if err != nil {
-			c.String(http.StatusBadRequest, err1.Error())
-		}
+	archiveId := c.Request.FormValue("archiveId")
+	archiveStruc, ok := accessibleArchive(c, userDetails, archiveId)
+	if !ok {
+		return
+	}
 
-		if archiveStruc.File_state != "uploaded" {
-			c.String(http.StatusInternalServerError, "The file you're trying to download has already been uploaded")
-		} else {
+	if archiveStruc.File_state != "uploaded" {
+		c.String(http.StatusConflict, "The file you're trying to download is not available in the vault")
+		return
+	}
 
-			start := time.Now()
-			log.Print("starting download file at")
-			log.Print(start)
+	// one restore per archive at a time, later requests follow the job already running
+	lock := archiveLock(archiveId)
+	lock.Lock()
+	defer lock.Unlock()
+	job, err := dba.GetActiveRestoreJob(c.Request.Context(), archiveId)
+	if err == nil {
+		restoreAccepted(c, archiveStruc, job.Job_id)
+		return
+	} else if err != sql.ErrNoRows {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
 
-			err := glacierDownload.Download(archiveStruc)
-			if err != nil {
-				c.String(http.StatusBadGateway, err.Error())
-			} else {
-				c.String(http.StatusOK, "download success")
+	orgId := userDetails["org_id"].(int)
+	period := time.Now().Format(spendingPeriod)
+	cost, ok := approveRestore(c, archiveStruc, orgId, period)
+	if !ok {
+		return
+	}
+
+	// the archive is cold, so start the restore and let the client follow the job
+	jobId, err := glacierJob.Glacier_InitiateRetrievalJob(c.Request.Context(), archiveStruc.Vault_file_id, archiveStruc.File_name)
+	if err != nil {
+		if cost > 0 {
+			// the restore didn't happen, so give its cost back to the budget
+			if err := dba.AddRestoreSpending(context.Background(), orgId, period, -cost); err != nil {
+				log.Printf("could not refund restore spending of organization %d: %v", orgId, err)
 			}
+		}
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+
+	size, _ := util.ParseHumanFileSize(archiveStruc.File_size)
+	job = util.RestoreJob{
+		Job_id:     jobId,
+		Archive_id: archiveId,
+		User_id:    userDetails["user_id"].(int),
+		Org_id:     orgId,
+		Status:     "in_progress",
+		Cost:       cost,
+		Bytes:      int64(size),
+		Created_at: time.Now().Format("2006-01-02 15:04:05"),
+		Owner:      instanceId,
+	}
+	if err := dba.InsertRestoreJob(c.Request.Context(), job); err != nil {
+		c.String(http.StatusInternalServerError, "restore job %s started but could not be recorded: %s", jobId, err.Error())
+		return
+	}
 
-			end := time.Now()
-			log.Print("finish download file at")
-			log.Print(end)
+	go waitForRestore(job, archiveStruc)
+	restoreAccepted(c, archiveStruc, jobId)
+}
+
+func restoreAccepted(c *gin.Context, a util.Archive, jobId string) {
+	SetChecksumHeader(c, a)
+	c.JSON(http.StatusAccepted, gin.H{"status": http.StatusAccepted, "data": gin.H{
+		"archive_id":  a.Vault_file_id,
+		"job_id":      jobId,
+		"status_code": glaciertypes.StatusCodeInProgress,
+	}})
+}
+
+// a fixed set of locks shared by all archives, so there is nothing to clean up once a restore started;
+// archives hashed to the same lock only wait for each other while their restore is being started
+var archiveLocks [64]sync.Mutex
+
+func archiveLock(archiveId string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(archiveId))
+	return &archiveLocks[h.Sum32()%uint32(len(archiveLocks))]
+}
+
+// Wait for a restore job, write the file to the download folder and notify the requester and the owner of the archive
+func waitForRestore(job util.RestoreJob, a util.Archive) {
+	start := time.Now()
+	log.Printf("waiting for retrieval job %s of archive %s", job.Job_id, job.Archive_id)
+	status := "completed"
+	err := glacierDownload.WaitAndDownload(job.Job_id, a)
+	if err != nil {
+		log.Printf("download of archive %s failed: %v", job.Archive_id, err)
+		status = "failed"
+	} else {
+		log.Printf("archive %s downloaded in %s", job.Archive_id, time.Since(start))
+	}
+	if err := dba.FinishRestoreJob(context.Background(), job.Job_id, status); err != nil {
+		log.Printf("could not record the end of retrieval job %s: %v", job.Job_id, err)
+	}
+	for _, notification := range RestoreNotifications(job, a, err) {
+		if err := dba.InsertNotification(context.Background(), notification); err != nil {
+			log.Printf("could not notify user %d: %v", notification.User_id, err)
 		}
 	}
 }
 
+// Notifications of the end of a restore: for the user who started it and, when someone
+// else of the organization did, for the owner of the archive too
+func RestoreNotifications(job util.RestoreJob, a util.Archive, restoreErr error) []util.Notification {
+	notification := util.Notification{Archive_id: job.Archive_id, Type: "restore_complete"}
+	notification.Message = fmt.Sprintf("%s has been restored from cold storage", a.File_name)
+	if restoreErr != nil {
+		notification.Type = "restore_failed"
+		notification.Message = fmt.Sprintf("The restore of %s failed", a.File_name)
+	}
+
+	users := []int{job.User_id}
+	if a.User_id != job.User_id {
+		users = append(users, a.User_id)
+	}
+	notifications := []util.Notification{}
+	for _, userId := range users {
+		notification.User_id = userId
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
+
+// Validate the token of a request, answering it when the token is missing or not valid
+func requestUser(c *gin.Context) (map[string]interface{}, bool) {
+	data := strings.Split(c.Request.Header.Get("Authorization"), "Token ")
+	if len(data) < 2 {
+		c.String(http.StatusBadRequest, errors.New("request not valid").Error())
+		return nil, false
+	}
+	userDetails, err := authenticate.ValidateToken(c.Request.Context(), data[1])
+	if err != nil {
+		c.String(http.StatusForbidden, errors.New("invalid token").Error())
+		return nil, false
+	}
+	c.Set("user_id", userDetails["user_id"])
+	c.Set("org_id", userDetails["org_id"])
+	return userDetails, true
+}
+
+// Get an archive the user owns or that belongs to someone of their organization,
+// answering the request when there is no such archive
+func accessibleArchive(c *gin.Context, userDetails map[string]interface{}, archiveId string) (util.Archive, bool) {
+	archiveStruc, err := dba.GetArchive(c.Request.Context(), archiveId)
+	if err == sql.ErrNoRows {
+		c.String(http.StatusNotFound, "archive not found")
+		return archiveStruc, false
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return archiveStruc, false
+	}
+	if archiveStruc.User_id == userDetails["user_id"].(int) {
+		return archiveStruc, true
+	}
+
+	ownerOrg, err := dba.GetUserOrganization(c.Request.Context(), archiveStruc.User_id)
+	if err != nil && err != sql.ErrNoRows {
+		c.String(http.StatusInternalServerError, err.Error())
+		return archiveStruc, false
+	}
+	if err == sql.ErrNoRows || ownerOrg != userDetails["org_id"].(int) {
+		// the same answer as a missing archive, so ids of other organizations can't be probed
+		c.String(http.StatusNotFound, "archive not found")
+		return archiveStruc, false
+	}
+	return archiveStruc, true
+}
+
+// Announce the stored SHA-256 of an archive, files uploaded before it was recorded have none
+func SetChecksumHeader(c *gin.Context, a util.Archive) {
+	if a.File_sha256 != "" {
+		c.Header("X-Checksum-SHA256", a.File_sha256)
+	}
+}
+
+// Check a restore against the pricing and charge it to the budget of the organization,
+// answering the request when the restore can't go ahead
+func approveRestore(c *gin.Context, a util.Archive, orgId int, period string) (cost float64, ok bool) {
+	if len(awsConfig.RestorePricing) == 0 {
+		return 0, true
+	}
+
+	estimate, err := glacierCost.EstimateRestore(a, glacierCost.DefaultTier)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return 0, false
+	}
+	if estimate.NeedsConfirm && c.Request.FormValue("confirm") != "true" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"status": http.StatusPreconditionRequired, "data": estimate})
+		return 0, false
+	}
+	if estimate.Cost <= 0 {
+		return 0, true
+	}
+
+	// checked and recorded in one step, so concurrent restores can't overspend
+	reserved, err := dba.ReserveRestoreSpending(c.Request.Context(), orgId, period, estimate.Cost)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "could not record the restore spending: %s", err.Error())
+		return 0, false
+	}
+	if !reserved {
+		c.String(http.StatusPaymentRequired, "the monthly restore budget of the organization is exhausted")
+		return 0, false
+	}
+	return estimate.Cost, true
+}
+
+func GetRestoreBudget(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	period := time.Now().Format(spendingPeriod)
+	budget, spent, err := dba.GetRestoreBudget(c.Request.Context(), orgId, period)
+	if err == sql.ErrNoRows {
+		c.String(http.StatusNotFound, "organization not found")
+		return
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := gin.H{"org_id": orgId, "period": period, "budget": nil, "spent": spent}
+	if budget.Valid {
+		data["budget"] = budget.Float64
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": data})
+}
+
+// Set the monthly restore budget of an organization, an empty budget removes the limit
+func SetRestoreBudget(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "organization id not valid")
+		return
+	}
+
+	budget := sql.NullFloat64{}
+	if value := c.PostForm("budget"); value != "" {
+		budget.Float64, err = strconv.ParseFloat(value, 64)
+		if err != nil || budget.Float64 < 0 {
+			c.String(http.StatusBadRequest, "budget not valid")
+			return
+		}
+		budget.Valid = true
+	}
+
+	if err := dba.SetRestoreBudget(c.Request.Context(), orgId, budget); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	GetRestoreBudget(c)
+}
+
+// Usage of every organization in a month or a day, as JSON or, with format=csv, as CSV for billing tools
+func BillingUsage(c *gin.Context) {
+	period := c.DefaultQuery("period", time.Now().Format(spendingPeriod))
+	// a month, or a single day of it
+	from, err := time.ParseInLocation(spendingPeriod, period, time.Local)
+	to := from.AddDate(0, 1, 0)
+	if err != nil {
+		from, err = time.ParseInLocation("2006-01-02", period, time.Local)
+		to = from.AddDate(0, 0, 1)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, "period not valid, expected YYYY-MM or YYYY-MM-DD")
+		return
+	}
+
+	usage, err := dba.GetOrgUsage(c.Request.Context(), period, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": usage})
+		return
+	}
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s.csv", period))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"org_id", "period", "archives_at_period_end", "stored_bytes_at_period_end", "stored_gib_days", "restored_bytes", "restore_cost"})
+	for _, u := range usage {
+		w.Write([]string{
+			strconv.Itoa(u.Org_id),
+			u.Period,
+			strconv.Itoa(u.Archives),
+			strconv.FormatFloat(u.Stored_bytes, 'f', 0, 64),
+			strconv.FormatFloat(u.Stored_gib_days, 'f', 4, 64),
+			strconv.FormatInt(u.Restored_bytes, 10),
+			strconv.FormatFloat(u.Restore_cost, 'f', 4, 64),
+		})
+	}
+	w.Flush()
+}
+
+func RestoreEstimate(c *gin.Context) {
+	userDetails, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	archiveStruc, ok := accessibleArchive(c, userDetails, c.Query("archiveId"))
+	if !ok {
+		return
+	}
+
+	estimate, err := glacierCost.EstimateRestore(archiveStruc, c.Query("tier"))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": estimate})
+}
+
+func DownloadStatus(c *gin.Context) {
+	userDetails, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	jobId := c.Query("jobId")
+	if jobId == "" {
+		c.String(http.StatusBadRequest, "please enter a not void jobId")
+		return
+	}
+
+	restore, err := dba.GetRestoreJob(c.Request.Context(), jobId)
+	if err != nil && err != sql.ErrNoRows {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err == sql.ErrNoRows || (restore.User_id != userDetails["user_id"].(int) && restore.Org_id != userDetails["org_id"].(int)) {
+		c.String(http.StatusNotFound, "job not found")
+		return
+	}
+
+	job, err := glacierJob.Glacier_DescribeJob(c.Request.Context(), jobId)
+	if err != nil {
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{
+		"archive_id":     aws.ToString(job.ArchiveId),
+		"job_id":         jobId,
+		"completed":      job.Completed,
+		"status_code":    job.StatusCode,
+		"status_message": aws.ToString(job.StatusMessage),
+		"restore_status": restore.Status,
+	}})
+}
+
 func JobInit() {}
 
 func ListJobs(cfg aws.Config) (*glacier.ListJobsOutput, error) {
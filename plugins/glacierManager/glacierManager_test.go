@@ -0,0 +1,132 @@
+package glacierManager_test
+
+import (
+	"context"
+	"cool-storage-api/authenticate"
+	"cool-storage-api/dba"
+	"cool-storage-api/plugins/glacierManager"
+	"cool-storage-api/register"
+	"cool-storage-api/util"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register a user with a password the default policy accepts, moved into orgId when it isn't 0,
+// and return its token and user id
+func newUser(t *testing.T, orgId int) (string, int) {
+	ctx := context.Background()
+	rand.Seed(time.Now().UnixNano())
+	email := fmt.Sprintf("user%d@example.com", rand.Intn(1000000000))
+	password := fmt.Sprintf("Test-Pass-%06d", rand.Intn(1000000))
+	if _, err := register.RegisterUser(ctx, email, password); err != nil {
+		t.Fatal(err)
+	}
+	if orgId != 0 {
+		db, err := dba.ObtenerBaseDeDatos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec("UPDATE system_users SET organization_org_id = ? WHERE email = ?", orgId, email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokenDetails, err := authenticate.GetToken(ctx, email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDetails, err := authenticate.ValidateToken(ctx, tokenDetails["auth_token"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenDetails["auth_token"], userDetails["user_id"].(int)
+}
+
+func newOrganization(t *testing.T) int {
+	db, err := dba.ObtenerBaseDeDatos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.Exec("INSERT INTO organization (org_name) VALUES(?)", "other organization")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgId, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(orgId)
+}
+
+func newArchive(t *testing.T, userId int) util.Archive {
+	a := util.Archive{
+		Vault_file_id: fmt.Sprintf("test-archive-%d", rand.Int63()),
+		Library_id:    1,
+		User_id:       userId,
+		File_name:     "report.pdf",
+		Upload_date:   time.Now().Format("2006-01-02 15:04:05"),
+		File_size:     "2 MB",
+		File_checksum: "checksum",
+		File_state:    "uploaded",
+	}
+	if err := dba.InsertArchive(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestRestoreEstimate_WithForeignOrganization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, ownerId := newUser(t, 0)
+	archive := newArchive(t, ownerId)
+	token, _ := newUser(t, newOrganization(t))
+
+	r := gin.New()
+	r.GET("/api/v1/restore-estimate", glacierManager.RestoreEstimate)
+	call := func(archiveId string, authorization string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/restore-estimate?archiveId="+archiveId, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// an archive of another organization looks exactly like a missing one
+	if code := call(archive.Vault_file_id, "Token "+token); code != http.StatusNotFound {
+		t.Errorf("Expected %v but got %v", http.StatusNotFound, code)
+	}
+	if code := call("missing-archive", "Token "+token); code != http.StatusNotFound {
+		t.Errorf("Expected %v but got %v", http.StatusNotFound, code)
+	}
+	if code := call(archive.Vault_file_id, ""); code != http.StatusBadRequest {
+		t.Errorf("Expected %v but got %v", http.StatusBadRequest, code)
+	}
+}
+
+func TestRestoreNotifications(t *testing.T) {
+	archive := util.Archive{Vault_file_id: "archive", User_id: 1, File_name: "report.pdf"}
+
+	// started by another member of the organization: both are told
+	job := util.RestoreJob{Job_id: "job", Archive_id: "archive", User_id: 2}
+	notifications := glacierManager.RestoreNotifications(job, archive, nil)
+	if len(notifications) != 2 || notifications[0].User_id != 2 || notifications[1].User_id != 1 || notifications[0].Type != "restore_complete" {
+		t.Errorf("Expected notifications for %v and %v but got %v", 2, 1, notifications)
+	}
+
+	job.User_id = 1
+	notifications = glacierManager.RestoreNotifications(job, archive, errors.New("job failed"))
+	if len(notifications) != 1 || notifications[0].User_id != 1 || notifications[0].Type != "restore_failed" {
+		t.Errorf("Expected a restore_failed notification for %v but got %v", 1, notifications)
+	}
+}
@@ -1,28 +1,29 @@
 package glacierUpload
 
 import (
-	"bytes"
 	"context"
 	configread "cool-storage-api/configread"
 	"cool-storage-api/dba"
 	"cool-storage-api/plugins/awsAuth"
+	"cool-storage-api/slowlog"
 	util "cool-storage-api/util"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/glacier"
 )
 
-func Upload(dst string, filename string, user_id int) error {
-	Ufile, err := ioutil.ReadFile(dst)
+// Upload a staged file to the vault and record it together with the SHA-256 of its content
+func Upload(ctx context.Context, dst string, filename string, fileHash string, user_id int) error {
+	Ufile, err := os.Open(dst)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Fail to load the file: %s", err))
 	}
+	defer Ufile.Close()
 
-	Rfile, err := os.Stat(dst)
+	Rfile, err := Ufile.Stat()
 	if err != nil {
 		return errors.New(fmt.Sprintf("Fail to load the size of the file: %s", err))
 	}
@@ -40,9 +41,11 @@ func Upload(dst string, filename string, user_id int) error {
 	input := glacier.UploadArchiveInput{
 		VaultName:          &vaultName,
 		ArchiveDescription: &filename,
-		Body:               bytes.NewReader(Ufile),
+		Body:               Ufile,
 	}
-	result, err := client.UploadArchive(context.TODO(), &input)
+	start := time.Now()
+	result, err := client.UploadArchive(ctx, &input)
+	slowlog.Record(slowlog.Entry{Kind: "storage", Name: "glacier.UploadArchive", UserId: user_id, Detail: filename}, start)
 	if err != nil {
 		return errors.New(fmt.Sprintf("failed to upload archive to AWS-Glacier: %s", err))
 	}
@@ -56,10 +59,12 @@ func Upload(dst string, filename string, user_id int) error {
 		Upload_date:   time.Now().Format("2006-01-02 15:04:05"),
 		File_size:     file_size,
 		File_checksum: *result.Checksum,
+		File_sha256:   fileHash,
 		File_state:    "uploaded",
 	}
 
-	response := dba.InsertArchive(archive_data)
+	// the archive is in Glacier already, so it's recorded even if the client went away meanwhile
+	response := dba.InsertArchive(context.Background(), archive_data)
 	if response != nil {
 		return errors.New(fmt.Sprintf("Error on save upload data to DB: %s", response.Error()))
 	}
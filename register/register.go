@@ -1,13 +1,123 @@
 package register
 
 import (
+	"context"
+	"cool-storage-api/configread"
 	"cool-storage-api/dba"
+	"cool-storage-api/util"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
 
 	_ "github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func RegisterUser(email string, password string) (string, error) {
+// Returned when an invite token is unknown, expired or meant for another email domain
+var ErrInvitationNotValid = errors.New("invitation not valid")
+
+// Returned when the installation already has as many users as license.maxUsers allows
+var ErrUserLimitReached = errors.New("the licensed number of users has been reached")
+
+// Returned when a password does not follow the password policy, listing every rule it breaks
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password must " + strings.Join(e.Problems, ", ")
+}
+
+// SHA-1 of breached passwords refused on registration, read once at startup
+var breachedPasswords = loadBreachedPasswords(configread.Configuration.PasswordPolicy.BreachedHashesFile)
+
+func loadBreachedPasswords(path string) map[string]bool {
+	if path == "" {
+		return map[string]bool{}
+	}
+	hashes, err := util.LoadPasswordHashList(path)
+	if err != nil {
+		panic(err)
+	}
+	return hashes
+}
+
+// Check a password against a policy and a list of SHA-1 of breached passwords
+func ValidatePassword(policy configread.PasswordConf, breached map[string]bool, password string) error {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	problems := []string{}
+	if length := len([]rune(password)); length < policy.MinLength {
+		problems = append(problems, fmt.Sprintf("be at least %d characters long", policy.MinLength))
+	}
+	if policy.RequireUpper && !upper {
+		problems = append(problems, "contain an upper case letter")
+	}
+	if policy.RequireLower && !lower {
+		problems = append(problems, "contain a lower case letter")
+	}
+	if policy.RequireDigit && !digit {
+		problems = append(problems, "contain a digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		problems = append(problems, "contain a symbol")
+	}
+	if breached[util.PasswordSHA1(password)] {
+		problems = append(problems, "not be a known breached password")
+	}
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
+
+func RegisterUser(ctx context.Context, email string, password string) (string, error) {
+	// open registrations land in the default organization
+	return registerUser(ctx, email, password, "no", 10, 1, "")
+}
+
+// Register a user into the organization of an invitation, with the quota it grants.
+// Invited users are never staff, staff is only granted in the database.
+func RegisterInvitedUser(ctx context.Context, email string, password string, inviteToken string) (string, error) {
+	inv, err := dba.GetInvitation(ctx, inviteToken)
+	if err == sql.ErrNoRows {
+		return "", ErrInvitationNotValid
+	} else if err != nil {
+		return "", err
+	}
+
+	expiresAt, err := time.ParseInLocation(inviteTimeLayout, inv.Expires_at, time.Local)
+	if err != nil || time.Now().After(expiresAt) || inv.Use_count >= inv.Max_uses {
+		return "", ErrInvitationNotValid
+	}
+	if inv.Email_domain != "" && !strings.HasSuffix(strings.ToLower(email), "@"+strings.ToLower(inv.Email_domain)) {
+		return "", ErrInvitationNotValid
+	}
+	return registerUser(ctx, email, password, "no", inv.Quota_total, inv.Org_id, inviteToken)
+}
+
+const inviteTimeLayout = "2006-01-02 15:04:05"
+
+// Insert a user, using up one registration of inviteToken in the same transaction when it's set
+func registerUser(ctx context.Context, email string, password string, isStaff string, quota int, orgId int, inviteToken string) (string, error) {
+	if err := ValidatePassword(configread.Configuration.PasswordPolicy, breachedPasswords, password); err != nil {
+		return "", err
+	}
 
 	// db, err := sql.Open("mysql", "sample_db_user:EXAMPLE_PASSWORD@tcp(host.docker.internal:33061)/sample_db")
 	db, err := dba.ObtenerBaseDeDatos()
@@ -16,15 +126,46 @@ func RegisterUser(email string, password string) (string, error) {
 	}
 	defer db.Close()
 	// make sure connection is available
-	err = db.Ping()
+	err = db.PingContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", err
 	}
+	defer tx.Rollback()
+
+	if inviteToken != "" {
+		// checked again here, so concurrent registrations can't use an invitation more often than it allows
+		res, err := tx.ExecContext(ctx, "UPDATE invitations SET use_count = use_count + 1 WHERE invite_token = ? AND use_count < max_uses AND expires_at > ?",
+			inviteToken, time.Now().Format(inviteTimeLayout))
+		if err != nil {
+			return "", err
+		}
+		if claimed, err := res.RowsAffected(); err != nil {
+			return "", err
+		} else if claimed == 0 {
+			return "", ErrInvitationNotValid
+		}
+	}
+
+	if maxUsers := configread.Configuration.LicenseConfig.MaxUsers; maxUsers > 0 {
+		// a locking read, so concurrent registrations wait for each other and can't go over the limit together
+		var users int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM system_users FOR UPDATE").Scan(&users); err != nil {
+			return "", err
+		}
+		if users >= maxUsers {
+			return "", ErrUserLimitReached
+		}
+	}
 
 	// queryString := "insert into system_users(username, password) values (?, ?)"
 	queryString := "insert into system_users(email, password, is_staff, name, avatar_url, quota_total, space_usage, organization_org_id) values (?, ?, ?, ?, ?, ?, ?, ?)"
 
-	stmt, err := db.Prepare(queryString)
+	stmt, err := tx.PrepareContext(ctx, queryString)
 	if err != nil {
 		return "", err
 	}
@@ -33,11 +174,14 @@ func RegisterUser(email string, password string) (string, error) {
 
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), 14)
 
-	// _, err = stmt.Exec(email, hashedPassword)
-	_, err = stmt.Exec(email, hashedPassword, "no", "", "", 10, 0, 1)
+	// _, err = stmt.ExecContext(ctx, email, hashedPassword)
+	_, err = stmt.ExecContext(ctx, email, hashedPassword, isStaff, "", "", quota, 0, orgId)
 	if err != nil {
 		return "", err
 	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
 
 	return "success", nil
 }
@@ -1,7 +1,10 @@
 package register_test
 
 import (
+	"context"
+	"cool-storage-api/configread"
 	"cool-storage-api/register"
+	"cool-storage-api/util"
 	"database/sql"
 	"math/rand"
 	"strconv"
@@ -14,7 +17,7 @@ import (
 func TestRegisterUser_WithRandomUser(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	randomUser := strconv.Itoa(rand.Intn(1000000))
-	randomPassword := strconv.Itoa(rand.Intn(1000000))
+	randomPassword := "Test-Password-" + strconv.Itoa(rand.Intn(1000000))
 	expectation := "success"
 
 	var count int
@@ -50,7 +53,7 @@ func TestRegisterUser_WithRandomUser(t *testing.T) {
 		t.Errorf(err1.Error())
 	}
 
-	result, err := register.RegisterUser(randomUser, randomPassword)
+	result, err := register.RegisterUser(context.Background(), randomUser, randomPassword)
 	if result == "" || err != nil {
 		t.Errorf("Expected %v,%v but got %v,%v", expectation, nil, result, err)
 	}
@@ -89,3 +92,45 @@ func TestRegisterUser_WithRandomUser(t *testing.T) {
 	}
 
 }
+
+func TestRegisterUser_OverUserLimit(t *testing.T) {
+	license := configread.Configuration.LicenseConfig
+	defer func() { configread.Configuration.LicenseConfig = license }()
+	// the test database already has users
+	configread.Configuration.LicenseConfig.MaxUsers = 1
+
+	rand.Seed(time.Now().UnixNano())
+	randomUser := strconv.Itoa(rand.Intn(1000000)) + "@example.com"
+	randomPassword := "Test-Password-" + strconv.Itoa(rand.Intn(1000000))
+	_, err := register.RegisterUser(context.Background(), randomUser, randomPassword)
+	if err != register.ErrUserLimitReached {
+		t.Errorf("Expected %v but got %v", register.ErrUserLimitReached, err)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	policy := configread.PasswordConf{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	err := register.ValidatePassword(policy, nil, "Correct-horse-7")
+	if err != nil {
+		t.Errorf("Expected %v but got %v", nil, err)
+	}
+
+	err = register.ValidatePassword(policy, nil, "short")
+	expectation := "password must be at least 10 characters long, contain an upper case letter, contain a digit, contain a symbol"
+	if err == nil || err.Error() != expectation {
+		t.Errorf("Expected %v but got %v", expectation, err)
+	}
+
+	err = register.ValidatePassword(configread.PasswordConf{}, nil, "x")
+	if err != nil {
+		t.Errorf("Expected %v but got %v", nil, err)
+	}
+
+	breached := map[string]bool{util.PasswordSHA1("Correct-horse-7"): true}
+	err = register.ValidatePassword(policy, breached, "Correct-horse-7")
+	expectation = "password must not be a known breached password"
+	if err == nil || err.Error() != expectation {
+		t.Errorf("Expected %v but got %v", expectation, err)
+	}
+}
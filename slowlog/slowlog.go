@@ -0,0 +1,94 @@
+package slowlog
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// number of slow entries kept for the admin endpoint
+const capacity = 200
+
+type Entry struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Method     string `json:"method,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	UserId     int    `json:"user_id,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	At         string `json:"at"`
+}
+
+var (
+	mu        sync.Mutex
+	threshold time.Duration
+	entries   []Entry
+	next      int
+)
+
+// Set the duration from which requests and calls are logged, 0 disables the log
+func SetThreshold(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	threshold = d
+}
+
+// Log a request or call that started at start if it took longer than the threshold
+func Record(e Entry, start time.Time) {
+	elapsed := time.Since(start)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+
+	e.DurationMs = elapsed.Milliseconds()
+	e.At = start.Format("2006-01-02 15:04:05")
+	log.Printf("slow %s %s %s took %dms (status %d, user %d) %s", e.Kind, e.Method, e.Name, e.DurationMs, e.Status, e.UserId, e.Detail)
+
+	if len(entries) < capacity {
+		entries = append(entries, e)
+	} else {
+		entries[next] = e
+	}
+	next = (next + 1) % capacity
+}
+
+// Slow entries kept so far, newest first
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make([]Entry, 0, len(entries))
+	for i := 1; i <= len(entries); i++ {
+		result = append(result, entries[(next-i+len(entries))%len(entries)])
+	}
+	return result
+}
+
+// Gin middleware recording slow HTTP requests
+func Middleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	name := c.FullPath()
+	if name == "" {
+		name = c.Request.URL.Path
+	}
+	Record(Entry{
+		Kind:   "request",
+		Name:   name,
+		Method: c.Request.Method,
+		Status: c.Writer.Status(),
+		UserId: c.GetInt("user_id"),
+	}, start)
+}
+
+func SlowlogResponse(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": Entries()})
+}
@@ -0,0 +1,44 @@
+package slowlog_test
+
+import (
+	"cool-storage-api/slowlog"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	slowlog.SetThreshold(time.Second)
+	defer slowlog.SetThreshold(0)
+
+	slowlog.Record(slowlog.Entry{Kind: "request", Name: "/fast"}, time.Now())
+	slowlog.Record(slowlog.Entry{Kind: "request", Name: "/slow"}, time.Now().Add(-2*time.Second))
+	slowlog.Record(slowlog.Entry{Kind: "storage", Name: "UploadArchive"}, time.Now().Add(-3*time.Second))
+
+	entries := slowlog.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected %v but got %v", 2, len(entries))
+	}
+	if entries[0].Name != "UploadArchive" || entries[1].Name != "/slow" {
+		t.Errorf("Expected %v,%v but got %v,%v", "UploadArchive", "/slow", entries[0].Name, entries[1].Name)
+	}
+	if entries[1].DurationMs < 2000 {
+		t.Errorf("Expected at least %v but got %v", 2000, entries[1].DurationMs)
+	}
+}
+
+func TestRecord_WithRingFull(t *testing.T) {
+	slowlog.SetThreshold(time.Millisecond)
+	defer slowlog.SetThreshold(0)
+
+	for i := 0; i < 250; i++ {
+		slowlog.Record(slowlog.Entry{Kind: "request", Name: "/slow", Status: i}, time.Now().Add(-time.Second))
+	}
+
+	entries := slowlog.Entries()
+	if len(entries) != 200 {
+		t.Fatalf("Expected %v but got %v", 200, len(entries))
+	}
+	if entries[0].Status != 249 {
+		t.Errorf("Expected %v but got %v", 249, entries[0].Status)
+	}
+}
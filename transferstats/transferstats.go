@@ -0,0 +1,59 @@
+package transferstats
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// weight given to the newest sample in the moving average
+	smoothing = 0.3
+	// a suggested chunk should take about this long to upload
+	targetChunkTime  = 5 * time.Second
+	minChunkSize     = 1 << 20
+	maxChunkSize     = 32 << 20
+	DefaultChunkSize = 5 << 20
+)
+
+var (
+	mu           sync.Mutex
+	uploadSpeeds = map[int]float64{}
+)
+
+// Record that size bytes uploaded by a user took elapsed to be received
+func RecordUpload(user_id int, size int64, elapsed time.Duration) {
+	if size <= 0 || elapsed <= 0 {
+		return
+	}
+	speed := float64(size) / elapsed.Seconds()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if prev, ok := uploadSpeeds[user_id]; ok {
+		speed = smoothing*speed + (1-smoothing)*prev
+	}
+	uploadSpeeds[user_id] = speed
+}
+
+// Recent average upload speed of a user in bytes per second, 0 if unknown
+func UploadSpeed(user_id int) float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return uploadSpeeds[user_id]
+}
+
+// Chunk size the uploader should start with for a user
+func SuggestedChunkSize(user_id int) int64 {
+	speed := UploadSpeed(user_id)
+	if speed == 0 {
+		return DefaultChunkSize
+	}
+	size := int64(speed * targetChunkTime.Seconds())
+	if size < minChunkSize {
+		return minChunkSize
+	}
+	if size > maxChunkSize {
+		return maxChunkSize
+	}
+	return size
+}
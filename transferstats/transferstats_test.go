@@ -0,0 +1,33 @@
+package transferstats_test
+
+import (
+	"cool-storage-api/transferstats"
+	"testing"
+	"time"
+)
+
+func TestSuggestedChunkSize_WithUnknownUser(t *testing.T) {
+	size := transferstats.SuggestedChunkSize(-1)
+	if size != transferstats.DefaultChunkSize {
+		t.Errorf("Expected %v but got %v", transferstats.DefaultChunkSize, size)
+	}
+}
+
+func TestRecordUpload(t *testing.T) {
+	userId := 1
+	transferstats.RecordUpload(userId, 4<<20, time.Second)
+	if speed := transferstats.UploadSpeed(userId); speed != 4<<20 {
+		t.Errorf("Expected %v but got %v", 4<<20, speed)
+	}
+	if size := transferstats.SuggestedChunkSize(userId); size != 20<<20 {
+		t.Errorf("Expected %v but got %v", 20<<20, size)
+	}
+
+	transferstats.RecordUpload(userId, 14<<20, time.Second)
+	if speed := transferstats.UploadSpeed(userId); speed != 7<<20 {
+		t.Errorf("Expected %v but got %v", 7<<20, speed)
+	}
+	if size := transferstats.SuggestedChunkSize(userId); size != 32<<20 {
+		t.Errorf("Expected %v but got %v", 32<<20, size)
+	}
+}
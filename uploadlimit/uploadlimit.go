@@ -0,0 +1,84 @@
+package uploadlimit
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exported at /debug/vars
+var (
+	inFlightVar = expvar.NewInt("upload_bytes_in_flight")
+	limitVar    = expvar.NewInt("upload_bytes_limit")
+	rejectedVar = expvar.NewInt("uploads_rejected")
+)
+
+// Bytes reserved for an upload sent without Content-Length (chunked) when none are configured
+const DefaultUnknownSize = 64 << 20
+
+var (
+	mu          sync.Mutex
+	inFlight    int64
+	limit       int64
+	unknownSize int64 = DefaultUnknownSize
+	retryAfter        = 5 * time.Second
+)
+
+// Set the upload bytes allowed in flight, 0 removes the limit, the bytes reserved for uploads of unknown size
+// and the wait suggested to rejected clients
+func Configure(maxBytes int64, unknown int64, retry time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	limit = maxBytes
+	unknownSize = DefaultUnknownSize
+	if unknown > 0 {
+		unknownSize = unknown
+	}
+	if retry > 0 {
+		retryAfter = retry
+	}
+	limitVar.Set(maxBytes)
+}
+
+func acquire(size int64) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	// a single upload larger than the limit still goes through on an idle server
+	if limit > 0 && inFlight > 0 && inFlight+size > limit {
+		return false
+	}
+	inFlight += size
+	inFlightVar.Set(inFlight)
+	return true
+}
+
+func release(size int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	inFlight -= size
+	inFlightVar.Set(inFlight)
+}
+
+// Gin middleware rejecting uploads with 503 while the server is saturated
+func Middleware(c *gin.Context) {
+	size := c.Request.ContentLength
+	if size < 0 {
+		// a chunked upload can be of any size, so it holds a configured share of the limit
+		mu.Lock()
+		size = unknownSize
+		mu.Unlock()
+	}
+	if !acquire(size) {
+		rejectedVar.Add(1)
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.String(http.StatusServiceUnavailable, "server busy, retry later")
+		c.Abort()
+		return
+	}
+	defer release(size)
+	c.Next()
+}
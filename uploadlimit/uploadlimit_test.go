@@ -0,0 +1,96 @@
+package uploadlimit_test
+
+import (
+	"cool-storage-api/uploadlimit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	uploadlimit.Configure(100, 0, 7*time.Second)
+	defer uploadlimit.Configure(0, 0, 0)
+
+	entered := make(chan bool)
+	finish := make(chan bool)
+	r := gin.New()
+	r.POST("/upload", uploadlimit.Middleware, func(c *gin.Context) {
+		entered <- true
+		<-finish
+		c.String(http.StatusOK, "success")
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan bool)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("a", 60)))
+		r.ServeHTTP(first, req)
+		done <- true
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("a", 60)))
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "7" {
+		t.Errorf("Expected %v,%v but got %v,%v", http.StatusServiceUnavailable, "7", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	finish <- true
+	<-done
+	if first.Code != http.StatusOK {
+		t.Errorf("Expected %v but got %v", http.StatusOK, first.Code)
+	}
+
+	go func() { <-entered; finish <- true }()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("a", 150)))
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected %v but got %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestMiddleware_WithUnknownSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	uploadlimit.Configure(100, 60, 7*time.Second)
+	defer uploadlimit.Configure(0, 0, 0)
+
+	entered := make(chan bool)
+	finish := make(chan bool)
+	r := gin.New()
+	r.POST("/upload", uploadlimit.Middleware, func(c *gin.Context) {
+		entered <- true
+		<-finish
+		c.String(http.StatusOK, "success")
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan bool)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, "/upload", strings.NewReader("chunked"))
+		req.ContentLength = -1
+		r.ServeHTTP(first, req)
+		done <- true
+	}()
+	<-entered
+
+	// the chunked upload holds 60 bytes of the limit, not 0
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("a", 60)))
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %v but got %v", http.StatusServiceUnavailable, w.Code)
+	}
+
+	finish <- true
+	<-done
+	if first.Code != http.StatusOK {
+		t.Errorf("Expected %v but got %v", http.StatusOK, first.Code)
+	}
+}
@@ -1,13 +1,22 @@
 package util
 
 import (
+	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Archive struct {
@@ -18,42 +27,362 @@ type Archive struct {
 	Upload_date   string
 	File_size     string
 	File_checksum string
+	File_sha256   string
 	File_state    string
 }
 
-func AppendData(path string, data []byte) {
+// Glacier retrieval job started for a download, Status is "in_progress", "completed" or "failed"
+type RestoreJob struct {
+	Job_id       string
+	Archive_id   string
+	User_id      int
+	Org_id       int
+	Status       string
+	Cost         float64
+	Bytes        int64
+	Created_at   string
+	Completed_at string
+	// instance of the server waiting for the job, empty when none is
+	Owner string
+}
+
+// Archives with the same content, and the space taken by all the copies but one
+type DuplicateGroup struct {
+	File_sha256  string
+	Files        []Archive
+	Wasted_space float64
+}
+
+// What an organization stored, restored and spent on restores in a period
+type OrgUsage struct {
+	Org_id int
+	Period string
+	// archives held and their size at the end of the period, or now for the current one
+	Archives     int
+	Stored_bytes float64
+	// storage over the period: GiB held times the days they were held
+	Stored_gib_days float64
+	// bytes and estimated cost of the restores started in the period
+	Restored_bytes int64
+	Restore_cost   float64
+}
+
+// Calls and bytes an organization may use per day and per month, 0 for no limit
+type ApiQuota struct {
+	Daily_calls   int64
+	Daily_bytes   int64
+	Monthly_calls int64
+	Monthly_bytes int64
+}
+
+// Calls and bytes an organization used in a day (YYYY-MM-DD) or a month (YYYY-MM)
+type ApiUsage struct {
+	Period string
+	Calls  int64
+	Bytes  int64
+}
+
+type Invitation struct {
+	Invite_token string
+	Org_id       int
+	Email_domain string
+	Quota_total  int
+	Created_by   int
+	Expires_at   string
+	// registrations the invitation allows, and how many it was used for
+	Max_uses  int
+	Use_count int
+}
+
+type Notification struct {
+	Notification_id int
+	User_id         int
+	Type            string
+	Message         string
+	Archive_id      string
+	Created_at      string
+	Seen            bool
+}
+
+func AppendData(path string, data []byte) error {
 	// If the file doesn't exist, create it, or append to the file
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if _, err := f.Write(data); err != nil {
-		log.Fatal(err)
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Append everything read from r to the file at path, creating it if needed
+func AppendReader(path string, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return written, err
+	}
+	return written, f.Close()
+}
+
+// Remove the files of dir ending in suffix that were not modified for longer than maxAge
+func RemoveStaleFiles(dir string, suffix string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
 	}
-	if err := f.Close(); err != nil {
-		log.Fatal(err)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
 	}
+	return nil
 }
 
-func HashingReadFile(path string) string {
+// Progress of a chunked upload, kept next to the staged file
+type ChunkProgress struct {
+	Chunk  int   // last chunk appended
+	Offset int64 // staged size before it
+	Size   int64 // staged size after it
+}
+
+var ErrChunkOutOfOrder = errors.New("chunk out of order")
+
+// Get the offset chunk has to be appended at in the file staged at path. The first chunk starts over,
+// a retry of the last one replaces it, anything else has to follow the last one on a file of the recorded size.
+func ChunkOffset(path string, progressPath string, chunk int) (int64, error) {
+	if chunk == 1 {
+		for _, p := range []string{path, progressPath} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+	progress, err := ReadChunkProgress(progressPath)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	switch {
+	case progress.Chunk > 0 && chunk == progress.Chunk && size >= progress.Offset:
+		return progress.Offset, os.Truncate(path, progress.Offset)
+	case chunk == progress.Chunk+1 && size == progress.Size:
+		return size, nil
+	}
+	return 0, fmt.Errorf("%w: got chunk %d, expected chunk %d", ErrChunkOutOfOrder, chunk, progress.Chunk+1)
+}
+
+// Read the progress saved by SaveChunkProgress, nothing staged yet when there is none
+func ReadChunkProgress(path string) (ChunkProgress, error) {
+	var progress ChunkProgress
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return progress, nil
+	} else if err != nil {
+		return progress, err
+	}
+	if _, err := fmt.Sscanf(string(data), "%d %d %d", &progress.Chunk, &progress.Offset, &progress.Size); err != nil {
+		return ChunkProgress{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return progress, nil
+}
+
+func SaveChunkProgress(path string, progress ChunkProgress) error {
+	return os.WriteFile(path, []byte(fmt.Sprintf("%d %d %d\n", progress.Chunk, progress.Offset, progress.Size)), 0644)
+}
+
+func HashingReadFile(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer f.Close()
 
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 var (
 	suffixes [5]string
 )
 
+const maxFileNameLength = 255
+
+// Days something held since start spent in the period running from from until to
+func HeldDays(start time.Time, from time.Time, to time.Time) float64 {
+	if start.After(from) {
+		from = start
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from).Hours() / 24
+}
+
+// Check that a file name sent by a client is a single, printable path component
+func ValidateFileName(name string) error {
+	if !utf8.ValidString(name) {
+		return errors.New("file name is not valid UTF-8")
+	}
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("file name %q not valid", name)
+	}
+	if len(name) > maxFileNameLength {
+		return fmt.Errorf("file name longer than %d bytes", maxFileNameLength)
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return fmt.Errorf("file name contains the invalid character %q", r)
+		}
+	}
+	return nil
+}
+
+// How file names that aren't in Unicode NFC form are handled
+const (
+	// refuse them, so clients have to normalize names themselves
+	UnicodeReject = "reject"
+	// store them in NFC form, so the same name typed on macOS and on Windows is one name
+	UnicodeNormalize = "normalize"
+)
+
+// Check a file name like ValidateFileName and bring it to Unicode NFC form, or refuse it
+// when it isn't in that form and policy is UnicodeReject
+func NormalizeFileName(name string, policy string) (string, error) {
+	if err := ValidateFileName(name); err != nil {
+		return "", err
+	}
+	if norm.NFC.IsNormalString(name) {
+		return name, nil
+	}
+	switch policy {
+	case UnicodeReject:
+		return "", fmt.Errorf("file name %q is not in Unicode NFC form", name)
+	case UnicodeNormalize, "":
+		name = norm.NFC.String(name)
+		return name, ValidateFileName(name)
+	}
+	return "", fmt.Errorf("unknown unicode policy %q", policy)
+}
+
+// Normalize a hex encoded SHA-256 sent by a client to the lower case form stored with archives
+func NormalizeSHA256(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if len(hash) != 2*sha256.Size {
+		return "", fmt.Errorf("hash %q is not a SHA-256", hash)
+	}
+	for _, r := range hash {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("hash %q is not a SHA-256", hash)
+		}
+	}
+	return hash, nil
+}
+
+// Read a list of SHA-256 hashes, one per line, ignoring blank lines and # comments
+func LoadHashList(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hashes := map[string]bool{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, err := NormalizeSHA256(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		hashes[hash] = true
+	}
+	return hashes, nil
+}
+
+// Read a list of breached passwords as SHA-1 hashes, one per line, in the format of the
+// Pwned Passwords downloads (HASH or HASH:COUNT), ignoring blank lines and # comments
+func LoadPasswordHashList(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hashes := map[string]bool{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash := strings.ToLower(strings.SplitN(line, ":", 2)[0])
+		if len(hash) != 2*sha1.Size || strings.Trim(hash, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("%s:%d: %q is not a SHA-1", path, i+1, hash)
+		}
+		hashes[hash] = true
+	}
+	return hashes, nil
+}
+
+// Hex encoded SHA-1 of a password, as listed in breached password lists
+func PasswordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Group archives by their SHA-256, keeping the groups with more than one archive in the order they first appear
+func GroupDuplicates(archives []Archive) []DuplicateGroup {
+	groups := []DuplicateGroup{}
+	index := map[string]int{}
+	for _, a := range archives {
+		if a.File_sha256 == "" {
+			continue
+		}
+		i, ok := index[a.File_sha256]
+		if !ok {
+			i = len(groups)
+			index[a.File_sha256] = i
+			groups = append(groups, DuplicateGroup{File_sha256: a.File_sha256})
+		}
+		groups[i].Files = append(groups[i].Files, a)
+	}
+
+	duplicates := []DuplicateGroup{}
+	for _, g := range groups {
+		if len(g.Files) < 2 {
+			continue
+		}
+		// sizes are stored rounded, so the wasted space is an approximation
+		size, _ := ParseHumanFileSize(g.Files[0].File_size)
+		g.Wasted_space = size * float64(len(g.Files)-1)
+		duplicates = append(duplicates, g)
+	}
+	return duplicates
+}
+
 func Round(val float64, roundOn float64, places int) (newVal float64) {
 	var round float64
 	pow := math.Pow(10, float64(places))
@@ -68,15 +397,39 @@ func Round(val float64, roundOn float64, places int) (newVal float64) {
 	return
 }
 
-func HumanFileSize(size float64) string {
+func initSuffixes() {
 	suffixes[0] = "B"
 	suffixes[1] = "KB"
 	suffixes[2] = "MB"
 	suffixes[3] = "GB"
 	suffixes[4] = "TB"
+}
+
+func HumanFileSize(size float64) string {
+	initSuffixes()
 
 	base := math.Log(size) / math.Log(1024)
 	getSize := Round(math.Pow(1024, base-math.Floor(base)), .5, 2)
 	getSuffix := suffixes[int(math.Floor(base))]
 	return strconv.FormatFloat(getSize, 'f', -1, 64) + " " + string(getSuffix)
 }
+
+// Get back the size in bytes of a size formatted by HumanFileSize
+func ParseHumanFileSize(size string) (float64, error) {
+	initSuffixes()
+
+	fields := strings.Fields(size)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid file size %q", size)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file size %q", size)
+	}
+	for i, suffix := range suffixes {
+		if fields[1] == suffix {
+			return value * math.Pow(1024, float64(i)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid file size %q", size)
+}
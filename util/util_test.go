@@ -0,0 +1,254 @@
+package util_test
+
+import (
+	"cool-storage-api/util"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.part")
+	fresh := filepath.Join(dir, "fresh.part")
+	other := filepath.Join(dir, "stale.txt")
+
+	for _, path := range []string{stale, fresh, other} {
+		if err := util.AppendData(path, []byte("chunk")); err != nil {
+			t.Fatalf("Expected %v but got %v", nil, err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale, old, old)
+	os.Chtimes(other, old, old)
+
+	err := util.RemoveStaleFiles(dir, ".part", time.Hour)
+	if err != nil {
+		t.Errorf("Expected %v but got %v", nil, err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected %v to be removed but got %v", stale, err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("Expected %v but got %v", nil, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected %v but got %v", nil, err)
+	}
+}
+
+func TestParseHumanFileSize(t *testing.T) {
+	for _, size := range []float64{512, 1536, 5 << 20, 3 << 30} {
+		got, err := util.ParseHumanFileSize(util.HumanFileSize(size))
+		if err != nil || got != size {
+			t.Errorf("Expected %v,%v but got %v,%v", size, nil, got, err)
+		}
+	}
+
+	_, err := util.ParseHumanFileSize("12 parsecs")
+	if err == nil {
+		t.Errorf("Expected an error but got %v", err)
+	}
+}
+
+func TestAppendReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.part")
+
+	for _, chunk := range []string{"first ", "second"} {
+		written, err := util.AppendReader(path, strings.NewReader(chunk))
+		if err != nil || written != int64(len(chunk)) {
+			t.Errorf("Expected %v,%v but got %v,%v", len(chunk), nil, written, err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "first second" {
+		t.Errorf("Expected %v,%v but got %v,%v", "first second", nil, string(data), err)
+	}
+}
+
+func TestChunkOffset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "upload.part")
+	progressPath := filepath.Join(dir, "upload.chunks.part")
+	appendChunk := func(chunk int, data string) {
+		offset, err := util.ChunkOffset(path, progressPath, chunk)
+		if err != nil {
+			t.Fatalf("Expected %v but got %v", nil, err)
+		}
+		written, _ := util.AppendReader(path, strings.NewReader(data))
+		util.SaveChunkProgress(progressPath, util.ChunkProgress{Chunk: chunk, Offset: offset, Size: offset + written})
+	}
+
+	// leftovers of an aborted upload are dropped by the first chunk
+	util.AppendData(path, []byte("stale"))
+	appendChunk(1, "first ")
+	appendChunk(2, "secnd")
+	// the retry of the last chunk replaces it
+	appendChunk(2, "second ")
+	appendChunk(3, "third")
+	data, _ := os.ReadFile(path)
+	if string(data) != "first second third" {
+		t.Errorf("Expected %v but got %v", "first second third", string(data))
+	}
+
+	for _, chunk := range []int{2, 5} {
+		if _, err := util.ChunkOffset(path, progressPath, chunk); !errors.Is(err, util.ErrChunkOutOfOrder) {
+			t.Errorf("Expected %v but got %v", util.ErrChunkOutOfOrder, err)
+		}
+	}
+	// the staged file doesn't match what was recorded
+	util.AppendData(path, []byte("!"))
+	if _, err := util.ChunkOffset(path, progressPath, 4); !errors.Is(err, util.ErrChunkOutOfOrder) {
+		t.Errorf("Expected %v but got %v", util.ErrChunkOutOfOrder, err)
+	}
+	os.Remove(path)
+	os.Remove(progressPath)
+	if _, err := util.ChunkOffset(path, progressPath, 4); !errors.Is(err, util.ErrChunkOutOfOrder) {
+		t.Errorf("Expected %v but got %v", util.ErrChunkOutOfOrder, err)
+	}
+}
+
+func TestHashingReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.part")
+	util.AppendData(path, []byte("hello world"))
+
+	hash, err := util.HashingReadFile(path)
+	expectation := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if err != nil || hash != expectation {
+		t.Errorf("Expected %v,%v but got %v,%v", expectation, nil, hash, err)
+	}
+
+	_, err = util.HashingReadFile(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Errorf("Expected an error but got %v", err)
+	}
+}
+
+func TestValidateFileName(t *testing.T) {
+	for _, name := range []string{"report.pdf", "informe año 2022.docx", "..hidden"} {
+		if err := util.ValidateFileName(name); err != nil {
+			t.Errorf("Expected %v but got %v for %q", nil, err, name)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../etc/passwd", `dir\file`, "tab\tname", "bad\xffutf8", strings.Repeat("a", 256)} {
+		if err := util.ValidateFileName(name); err == nil {
+			t.Errorf("Expected an error but got %v for %q", err, name)
+		}
+	}
+}
+
+func TestNormalizeFileName(t *testing.T) {
+	composed, decomposed := "informe a\u00f1o.docx", "informe an\u0303o.docx"
+
+	for _, policy := range []string{util.UnicodeNormalize, util.UnicodeReject} {
+		name, err := util.NormalizeFileName(composed, policy)
+		if err != nil || name != composed {
+			t.Errorf("Expected %q,%v but got %q,%v", composed, nil, name, err)
+		}
+	}
+
+	name, err := util.NormalizeFileName(decomposed, util.UnicodeNormalize)
+	if err != nil || name != composed {
+		t.Errorf("Expected %q,%v but got %q,%v", composed, nil, name, err)
+	}
+	if _, err := util.NormalizeFileName(decomposed, util.UnicodeReject); err == nil {
+		t.Errorf("Expected an error but got %v for %q", err, decomposed)
+	}
+	if _, err := util.NormalizeFileName("../etc/passwd", util.UnicodeNormalize); err == nil {
+		t.Errorf("Expected an error but got %v", err)
+	}
+}
+
+func TestHeldDays(t *testing.T) {
+	from := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	for _, test := range []struct {
+		start    time.Time
+		expected float64
+	}{
+		{from.AddDate(0, -1, 0), 30},
+		{from.AddDate(0, 0, 10), 20},
+		{from.Add(36 * time.Hour), 28.5},
+		{to, 0},
+		{to.AddDate(0, 0, 1), 0},
+	} {
+		if days := util.HeldDays(test.start, from, to); days != test.expected {
+			t.Errorf("Expected %v but got %v for %v", test.expected, days, test.start)
+		}
+	}
+}
+
+func TestNormalizeSHA256(t *testing.T) {
+	expectation := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	hash, err := util.NormalizeSHA256(" B94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9 ")
+	if err != nil || hash != expectation {
+		t.Errorf("Expected %v,%v but got %v,%v", expectation, nil, hash, err)
+	}
+
+	for _, hash := range []string{"", "b94d27b9", strings.Repeat("g", 64)} {
+		if _, err := util.NormalizeSHA256(hash); err == nil {
+			t.Errorf("Expected an error but got %v for %q", err, hash)
+		}
+	}
+}
+
+func TestLoadPasswordHashList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# most common\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n\n7c4a8d09ca3762af61e59520943dc26494f8941b\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := util.LoadPasswordHashList(path)
+	if err != nil || len(hashes) != 2 || !hashes[util.PasswordSHA1("password")] || !hashes[util.PasswordSHA1("123456")] {
+		t.Errorf("Expected %v hashes but got %v,%v", 2, hashes, err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a hash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.LoadPasswordHashList(path); err == nil {
+		t.Errorf("Expected an error but got %v", err)
+	}
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	archives := []util.Archive{
+		{Vault_file_id: "a", File_sha256: "aaaa", File_size: "2 MB"},
+		{Vault_file_id: "b", File_sha256: "bbbb", File_size: "1 KB"},
+		{Vault_file_id: "c", File_sha256: "aaaa", File_size: "2 MB"},
+		{Vault_file_id: "d", File_sha256: "", File_size: "1 KB"},
+		{Vault_file_id: "e", File_sha256: "aaaa", File_size: "2 MB"},
+		{Vault_file_id: "f", File_sha256: "", File_size: "1 KB"},
+	}
+
+	groups := util.GroupDuplicates(archives)
+	if len(groups) != 1 || groups[0].File_sha256 != "aaaa" || len(groups[0].Files) != 3 {
+		t.Fatalf("Expected %v group of %v files but got %v", 1, 3, groups)
+	}
+	if groups[0].Wasted_space != 4<<20 {
+		t.Errorf("Expected %v but got %v", 4<<20, groups[0].Wasted_space)
+	}
+}
+
+func TestLoadHashList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked.txt")
+	util.AppendData(path, []byte("# known bad files\n\nB94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9\n"))
+
+	hashes, err := util.LoadHashList(path)
+	if err != nil || len(hashes) != 1 || !hashes["b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"] {
+		t.Errorf("Expected %v,%v but got %v,%v", 1, nil, hashes, err)
+	}
+
+	util.AppendData(path, []byte("not a hash\n"))
+	_, err = util.LoadHashList(path)
+	if err == nil {
+		t.Errorf("Expected an error but got %v", err)
+	}
+}